@@ -0,0 +1,127 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// collectionTarget returns the slice, array or map pointed to by out, if out is a non-nil pointer to one.
+func collectionTarget(out any) (reflect.Value, bool) {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return reflect.Value{}, false
+	}
+
+	switch value := ptr.Elem(); value.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return value, true
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// loadCollection populates a top-level slice, array or map. A slice or array is parsed from the single variable
+// named by the prefix, while a map holds every variable beginning with the prefix, keyed by the remainder of its name.
+// Maps with struct values are populated just like struct map fields, see loadStructMap.
+func (d *decodeState) loadCollection(value reflect.Value) error {
+	prefix := d.joinPrefix(d.opts.prefix)
+	if value.Kind() != reflect.Map {
+		name := strings.TrimSuffix(prefix, d.opts.prefixSeparator)
+		if name == "" {
+			return fmt.Errorf("a prefix naming the environment variable is required to unmarshal into %s", value.Type())
+		}
+
+		v, _, ok := d.lookup(d.source, name)
+		if !ok {
+			return nil
+		}
+
+		setter, err := d.validateFieldAndReturnSetter(value, fieldTag{})
+		if err == nil {
+			err = setter.Set(v, value)
+		}
+		return collectionError(err, name, v)
+	}
+
+	if d.isStructMap(value.Type()) {
+		_, err := d.loadStructMap(value, fieldTag{}, "", d.opts.prefix, scope{}, func(err error) error {
+			return collectionError(err, prefix, "")
+		})
+		return err
+	}
+
+	keySetter, err := d.scalarSetter(value.Type().Key())
+	if err != nil {
+		return collectionError(unsupportedTypeError(value.Type()), prefix, "")
+	}
+
+	valueSetter, err := d.elementSetter(value.Type().Elem())
+	if err != nil {
+		return collectionError(unsupportedTypeError(value.Type()), prefix, "")
+	}
+
+	vars := d.prefixedVars(d.fold(prefix))
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := reflect.MakeMapWithSize(value.Type(), len(vars))
+	for _, name := range names {
+		key := name[len(prefix):]
+		keyValue := reflect.New(value.Type().Key()).Elem()
+		if err := keySetter.Set(key, keyValue); err != nil {
+			return collectionError(elementError{key, err}, name, vars[name])
+		}
+
+		elemValue := reflect.New(value.Type().Elem()).Elem()
+		if err := valueSetter.Set(vars[name], elemValue); err != nil {
+			return collectionError(elementError{key, err}, name, vars[name])
+		}
+
+		result.SetMapIndex(keyValue, elemValue)
+	}
+
+	value.Set(result)
+	return nil
+}
+
+// prefixedVars returns the variables in the enumerable sources beginning with prefix, excluding the prefix itself.
+// A variable in the source takes precedence over one in a fallback, just as when looking up a field's value.
+func (d *decodeState) prefixedVars(prefix string) map[string]string {
+	vars := make(map[string]string)
+	sources := append([]Source{d.source}, d.fallbacks...)
+	for i := len(sources) - 1; i >= 0; i-- {
+		if src, ok := sources[i].(MapSource); ok {
+			for name, value := range src {
+				if len(name) > len(prefix) && strings.HasPrefix(name, prefix) {
+					vars[name] = value
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// collectionError returns err as a FieldParseError for a top-level collection, whose field is identified solely by
+// the index or key of the element which failed to parse, if any.
+func collectionError(err error, envVar, value string) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(FieldParseError); ok {
+		return err
+	}
+
+	var field string
+	if elemErr, ok := err.(elementError); ok {
+		field = fmt.Sprintf("[%s]", elemErr.key)
+		err = elemErr.err
+	}
+
+	return newFieldParseError(err, field, envVar, value)
+}
@@ -0,0 +1,60 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalPrefixCollections(t *testing.T) {
+	env := []string{"LABELS_TEAM=core", "LABELS_TIER=1", "LABELS=ignored", "HOSTS=a,b", "PORTS_HTTP=80", "PORTS_HTTPS=443"}
+
+	var labels map[string]string
+	if err := UnmarshalPrefix(env, &labels, "LABELS"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(labels, map[string]string{"TEAM": "core", "TIER": "1"}) {
+		t.Fatalf("Expected the prefixed variables, got %v", labels)
+	}
+
+	var ports map[string]int
+	if err := UnmarshalPrefix(env, &ports, "PORTS_"); err != nil || ports["HTTPS"] != 443 {
+		t.Fatalf("Expected ports to be parsed, got %v (%v)", ports, err)
+	}
+
+	var hosts []string
+	if err := UnmarshalPrefix(env, &hosts, "HOSTS"); err != nil || !reflect.DeepEqual(hosts, []string{"a", "b"}) {
+		t.Fatalf("Expected hosts to be [a b], got %v (%v)", hosts, err)
+	}
+
+	var missing []string
+	if err := UnmarshalPrefix(env, &missing, "MISSING"); err != nil || missing != nil {
+		t.Fatalf("Expected a slice without a variable to be left untouched, got %v (%v)", missing, err)
+	}
+
+	servers := map[string]struct{ Port int }{}
+	if err := UnmarshalPrefix([]string{"SERVERS_WEB_PORT=8080"}, &servers, "SERVERS"); err != nil || servers["WEB"].Port != 8080 {
+		t.Fatalf("Expected struct values to be populated, got %v (%v)", servers, err)
+	}
+}
+
+func TestUnmarshalPrefixCollectionErrors(t *testing.T) {
+	var fieldErr FieldParseError
+
+	var ports map[string]int
+	err := UnmarshalPrefix([]string{"PORTS_HTTP=http"}, &ports, "PORTS")
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "[HTTP]" || fieldErr.EnvVar() != "PORTS_HTTP" {
+		t.Fatalf("Expected a FieldParseError for PORTS_HTTP, got %v", err)
+	}
+
+	var hosts []int
+	err = UnmarshalPrefix([]string{"HOSTS=1,b"}, &hosts, "HOSTS")
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "[1]" || fieldErr.EnvVar() != "HOSTS" {
+		t.Fatalf("Expected a FieldParseError for HOSTS, got %v", err)
+	}
+
+	if err := Unmarshal([]string{"HOSTS=a"}, &hosts); err == nil {
+		t.Fatal("Expected an error for a slice without a prefix")
+	}
+}
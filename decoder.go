@@ -0,0 +1,29 @@
+package env
+
+import "context"
+
+// Decoder unmarshals environment variables into structs using a fixed set of options, such that the options
+// need only be provided once when unmarshaling many structs. A Decoder is safe for concurrent use.
+type Decoder struct {
+	opts options
+}
+
+// NewDecoder returns a Decoder which applies opts to every call to [Decoder.Decode].
+func NewDecoder(opts ...Option) *Decoder {
+	return &Decoder{opts: newOptions(opts)}
+}
+
+// Decode is just like [Unmarshal], using the options the Decoder was created with.
+func (d *Decoder) Decode(env []string, out any) error {
+	return d.DecodeContext(context.Background(), env, out)
+}
+
+// DecodeContext is just like [UnmarshalContext], using the options the Decoder was created with.
+func (d *Decoder) DecodeContext(ctx context.Context, env []string, out any) error {
+	return newEnvDecodeState(ctx, env, d.opts).unmarshal(out)
+}
+
+// DecodeSource is just like [UnmarshalSource], using the options the Decoder was created with.
+func (d *Decoder) DecodeSource(src Source, out any) error {
+	return newDecodeState(context.Background(), src, d.opts).unmarshal(out)
+}
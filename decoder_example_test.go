@@ -0,0 +1,42 @@
+package env_test
+
+import (
+	"fmt"
+	"github.com/rad12000/go-env"
+	"net/mail"
+)
+
+func ExampleDecoder() {
+	decoder := env.NewDecoder(
+		env.WithPrefix("APP"),
+		env.WithTypeParser(mail.ParseAddress),
+	)
+
+	var server struct {
+		Port int
+	}
+
+	var notifications struct {
+		From *mail.Address
+		To   []*mail.Address
+	}
+
+	environ := []string{
+		"APP_PORT=8080",
+		"APP_FROM=Alerts <alerts@example.com>",
+		"APP_TO=ops@example.com,dev@example.com",
+	}
+
+	fmt.Println(decoder.Decode(environ, &server))
+	fmt.Println(decoder.Decode(environ, &notifications))
+	fmt.Println("port =", server.Port)
+	fmt.Println("from =", notifications.From)
+	fmt.Println("to =", notifications.To)
+
+	// Output:
+	// <nil>
+	// <nil>
+	// port = 8080
+	// from = "Alerts" <alerts@example.com>
+	// to = [<ops@example.com> <dev@example.com>]
+}
@@ -0,0 +1,40 @@
+package env
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	defaultFuncsMu sync.RWMutex
+	defaultFuncs   = make(map[string]func() (string, error))
+)
+
+// RegisterDefaultFunc registers a function, which may be referenced by name via the `env:",defaultFunc="` tag
+// to compute the default value of a field, e.g. `env:",defaultFunc=hostname"`. The function is only invoked when
+// the field's environment variable is not set, and the field has no `env:",default="` tag. An error returned by
+// the function is returned from [Unmarshal] as a [FieldParseError].
+//
+// RegisterDefaultFunc panics if fn is nil. Registering a function with a name that is already registered
+// replaces the existing function.
+func RegisterDefaultFunc(name string, fn func() (string, error)) {
+	if fn == nil {
+		panic("env: RegisterDefaultFunc fn is nil")
+	}
+
+	defaultFuncsMu.Lock()
+	defer defaultFuncsMu.Unlock()
+	defaultFuncs[name] = fn
+}
+
+func callDefaultFunc(name string) (string, error) {
+	defaultFuncsMu.RLock()
+	fn, ok := defaultFuncs[name]
+	defaultFuncsMu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown default function %q", name)
+	}
+
+	return fn()
+}
@@ -0,0 +1,40 @@
+package env_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rad12000/go-env"
+)
+
+func ExampleRegisterDefaultFunc() {
+	env.RegisterDefaultFunc("instanceID", func() (string, error) {
+		return "instance-1234", nil
+	})
+
+	env.RegisterDefaultFunc("region", func() (string, error) {
+		return "", errors.New("metadata service unavailable")
+	})
+
+	var out struct {
+		InstanceID string `env:",defaultFunc=instanceID"`
+	}
+
+	fmt.Println(env.Unmarshal(nil, &out))
+	fmt.Println("instance id =", out.InstanceID)
+
+	fmt.Println(env.Unmarshal([]string{"INSTANCE_ID=from-env"}, &out))
+	fmt.Println("instance id =", out.InstanceID)
+
+	var failing struct {
+		Region string `env:",defaultFunc=region"`
+	}
+
+	fmt.Println(env.Unmarshal(nil, &failing))
+
+	// Output:
+	// <nil>
+	// instance id = instance-1234
+	// <nil>
+	// instance id = from-env
+	// failed to unmarshal environment variables into struct *struct { Region string "env:\",defaultFunc=region\"" }: failed to unmarshal environment variable "REGION" into field "Region": metadata service unavailable
+}
@@ -0,0 +1,63 @@
+package env
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// UnmarshalWithDefaults is just like [Unmarshal], but falls back to the values of the fields of defaults, which must
+// be a struct of the same type as the one pointed to by out, or a pointer to one. This allows defaults to be defined
+// in code as a typed value, rather than as strings via the `env:",default="` tag.
+//
+// A field of defaults is only used when no value is found for the corresponding field of out, and it has neither a
+// `default` nor a `defaultFunc` tag, which take precedence. Fields of defaults holding their zero value provide no
+// default, such that a field with no value found is left untouched, just as by [Unmarshal]. The fields of nested
+// structs are matched individually, such that a nested struct may be partially defaulted. A default satisfies the
+// `required` option, and is set as is, without being validated. Note that slices, maps and pointers are shared with
+// defaults, rather than copied.
+func UnmarshalWithDefaults(env []string, out any, defaults any, opts ...Option) error {
+	value, err := targetValue(out)
+	if err != nil {
+		return err
+	}
+
+	defaultsValue := reflect.ValueOf(defaults)
+	if defaultsValue.Kind() == reflect.Pointer && !defaultsValue.IsNil() {
+		defaultsValue = defaultsValue.Elem()
+	}
+
+	if !defaultsValue.IsValid() || defaultsValue.Type() != value.Type() {
+		return fmt.Errorf("%w: defaults of type %T do not match %T", ErrInvalidTarget, defaults, out)
+	}
+
+	// Unexported fields may only be read via unsafe when the struct is addressable.
+	addressable := reflect.New(value.Type()).Elem()
+	addressable.Set(defaultsValue)
+
+	d := newEnvDecodeState(context.Background(), env, newOptions(opts))
+	d.defaults = make(map[string]reflect.Value)
+	d.collectDefaults(addressable, "")
+	return d.unmarshal(out)
+}
+
+// collectDefaults records the non-zero fields of the struct v, and those of the structs nested within it, by path.
+func (d *decodeState) collectDefaults(v reflect.Value, fieldPathPrefix string) {
+	for i := 0; i < v.NumField(); i++ {
+		field, ok := d.structField(v, i)
+		if !ok {
+			continue
+		}
+
+		fieldType := v.Type().Field(i)
+		fTag, _ := parseFieldTag(fieldType.Tag.Get("env"))
+		fieldPath := fieldPathPrefix + fieldType.Name
+		switch {
+		case fTag.Name == "-":
+		case d.isNestedStruct(field.Type(), fTag):
+			d.collectDefaults(field, fieldPath+".")
+		case !field.IsZero():
+			d.defaults[fieldPath] = field
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package env_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rad12000/go-env"
+)
+
+func ExampleUnmarshalWithDefaults() {
+	type Config struct {
+		Port    int
+		Timeout time.Duration
+		Hosts   []string
+		Workers int `env:",default=4"`
+	}
+
+	defaults := Config{Port: 8080, Timeout: 5 * time.Second, Hosts: []string{"localhost"}, Workers: 1}
+
+	var out Config
+	err := env.UnmarshalWithDefaults([]string{"PORT=9090"}, &out, defaults)
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {Port:9090 Timeout:5s Hosts:[localhost] Workers:4}
+}
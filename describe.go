@@ -0,0 +1,89 @@
+package env
+
+import (
+	"context"
+	"reflect"
+)
+
+// FieldInfo describes how a struct field is populated from the environment.
+type FieldInfo struct {
+	// Field is the path of the field, e.g. "Auth.SigningKey".
+	Field string
+	// EnvVar is the name of the environment variable the field is populated from.
+	EnvVar string
+	// Aliases are the alternative names specified via the `env:",alias="` tag.
+	Aliases []string
+	// Description is the text specified via the `env:",desc="` tag.
+	Description string
+	// Default is the value specified via the `env:",default="` tag, which is only meaningful when HasDefault is true.
+	Default    string
+	HasDefault bool
+	// Required reports whether the field was tagged with the `required` option.
+	Required bool
+	// Secret reports whether the field was tagged with the `secret` option.
+	Secret bool
+}
+
+// Describe returns information about every field of the struct pointed to by out which would be populated by
+// [Unmarshal] when called with the same options, in the order the fields would be processed. Nested structs are
+// not described themselves, but their fields are. This allows a struct to act as the single source of truth for
+// generating documentation of its configuration, e.g. a --help listing.
+//
+// Descriptions are specified via the `desc` tag option, e.g. `env:"PORT,desc=The port to listen on"`. Every word
+// following desc is part of the description, keywords included, so other options must precede it. The \s escape for
+// spaces is supported too.
+//
+// Describe returns nil if out is not a non-nil pointer to a struct, including when it points to one of the
+// collections supported by [Unmarshal].
+func Describe(out any, opts ...Option) []FieldInfo {
+	value, err := targetValue(out)
+	if err != nil {
+		return nil
+	}
+
+	d := newDecodeState(context.Background(), MapSource(nil), newOptions(opts))
+	return d.describeStruct(value.Type(), scope{envVarPrefix: d.joinPrefix(d.opts.prefix)}, nil)
+}
+
+func (d *decodeState) describeStruct(structType reflect.Type, s scope, infos []FieldInfo) []FieldInfo {
+	s = d.structScope(structType, s)
+	for i := 0; i < structType.NumField(); i++ {
+		fieldType := structType.Field(i)
+		if (!fieldType.IsExported() && !d.opts.allowUnexported && !isEmbeddedStruct(fieldType)) || fieldType.Name == "_" {
+			continue
+		}
+
+		fTag, _ := parseFieldTag(fieldType.Tag.Get("env"))
+		if fTag.Name == "-" || d.opts.ignoreFields[s.fieldPathPrefix+fieldType.Name] {
+			continue
+		}
+
+		var (
+			envName   = d.fieldEnvName(fieldType, fTag, s)
+			fieldPath = s.fieldPathPrefix + fieldType.Name
+			t         = fieldType.Type
+		)
+
+		if fieldType.Anonymous && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		if d.isNestedStruct(t, fTag) {
+			infos = d.describeStruct(t, d.nestedScope(fieldType, fTag, fieldPath, envName, s), infos)
+			continue
+		}
+
+		infos = append(infos, FieldInfo{
+			Field:       fieldPath,
+			EnvVar:      envName,
+			Aliases:     fTag.Aliases,
+			Description: fTag.Description,
+			Default:     fTag.Default,
+			HasDefault:  fTag.HasDefault,
+			Required:    d.isRequired(t, fTag),
+			Secret:      fTag.Secret,
+		})
+	}
+
+	return infos
+}
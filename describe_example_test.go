@@ -0,0 +1,24 @@
+package env_test
+
+import (
+	"fmt"
+	"github.com/rad12000/go-env"
+)
+
+func ExampleDescribe() {
+	var config struct {
+		Port int `env:",default=8080 desc=The port to listen on"`
+		Auth struct {
+			SigningKey string `env:",required secret desc=Key used to sign tokens, kept secret"`
+		}
+	}
+
+	for _, field := range env.Describe(&config, env.WithPrefix("APP")) {
+		fmt.Printf("%s (%s): %s [required=%t default=%q]\n",
+			field.EnvVar, field.Field, field.Description, field.Required, field.Default)
+	}
+
+	// Output:
+	// APP_PORT (Port): The port to listen on [required=false default="8080"]
+	// APP_AUTH_SIGNING_KEY (Auth.SigningKey): Key used to sign tokens, kept secret [required=true default=""]
+}
@@ -0,0 +1,65 @@
+package env
+
+import (
+	"context"
+	"reflect"
+)
+
+// EnvDiff reports how the environment variables provided to [Diff] correspond to the fields of a struct.
+type EnvDiff struct {
+	// Used are the names of the environment variables which fields were populated from, in the order the fields
+	// were processed. This includes aliases, variables read via [WithFileSuffix], those found via [WithFallback] and
+	// those named by the `env:",defaultFrom="` tag.
+	Used []string
+	// Defaulted are the paths of the fields (e.g. "Auth.SigningKey") populated via the `env:",default="` or
+	// `env:",defaultFunc="` tags.
+	Defaulted []string
+	// Unset are the paths of the fields for which no value was found.
+	Unset []string
+	// Unknown are the sorted names of the environment variables which do not correspond to any field. When a prefix
+	// is provided via [WithPrefix], only the variables beginning with the prefix are considered.
+	Unknown []string
+}
+
+// Diff runs the same resolution as [Unmarshal] against a zero value of the struct type pointed to by out, leaving
+// out untouched, and reports which environment variables were used, which defaults were applied, which fields had
+// no source, and which variables do not correspond to any field. This allows operators to audit an environment
+// against the configuration it is expected to provide.
+//
+// Like [ValidateEnv], every invalid field is reported in the returned error, which wraps an [AggregateError].
+// The diff is complete even when an error is returned for invalid fields. Unlike [Unmarshal], Diff only supports
+// structs, returning an error wrapping [ErrInvalidTarget] for a collection.
+func Diff(env []string, out any, opts ...Option) (EnvDiff, error) {
+	value, err := targetValue(out)
+	if err != nil {
+		return EnvDiff{}, err
+	}
+
+	var (
+		diff  EnvDiff
+		o     = newOptions(opts)
+		trace = o.trace
+	)
+
+	o.trace = func(fieldPath, envVar string, found bool, source string) {
+		switch source {
+		case "":
+			diff.Unset = append(diff.Unset, fieldPath)
+		case "default", "defaultFunc":
+			diff.Defaulted = append(diff.Defaulted, fieldPath)
+		default:
+			diff.Used = append(diff.Used, envVar)
+		}
+
+		if trace != nil {
+			trace(fieldPath, envVar, found, source)
+		}
+	}
+
+	d := newEnvDecodeState(context.Background(), env, o)
+	d.collectErrors = true
+	err = d.unmarshal(reflect.New(value.Type()).Interface())
+
+	diff.Unknown = d.unknownEnvVars(d.source.(MapSource), o.prefix)
+	return diff, err
+}
@@ -0,0 +1,31 @@
+package env_test
+
+import (
+	"fmt"
+	"github.com/rad12000/go-env"
+)
+
+func ExampleDiff() {
+	var config struct {
+		Host    string `env:",alias=SERVER_HOST"`
+		Port    int    `env:",default=8080"`
+		Timeout int
+		Auth    struct {
+			SigningKey string `env:",required"`
+		}
+	}
+
+	diff, err := env.Diff([]string{"SERVER_HOST=localhost", "APP_DEBUG=true", "PATH=/bin"}, &config)
+	fmt.Println("Used:", diff.Used)
+	fmt.Println("Defaulted:", diff.Defaulted)
+	fmt.Println("Unset:", diff.Unset)
+	fmt.Println("Unknown:", diff.Unknown)
+	fmt.Println("Valid:", err == nil)
+
+	// Output:
+	// Used: [SERVER_HOST]
+	// Defaulted: [Port]
+	// Unset: [Timeout Auth.SigningKey]
+	// Unknown: [APP_DEBUG PATH]
+	// Valid: false
+}
@@ -0,0 +1,77 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	enumsMu sync.RWMutex
+	enums   = make(map[reflect.Type]map[string]reflect.Value)
+)
+
+// RegisterEnum registers the values of the enum type T by name, such that fields of type T, or pointers to T, are
+// set to the value whose name matches the environment variable exactly. This allows the many small enum types of a
+// codebase, typically integers with a String method, to be unmarshaled without each implementing [Unmarshaler].
+// The elements of slices, arrays and maps of T are supported too, and [Marshal] formats each value as its name,
+// returning a [FieldParseError] for a value which was not registered.
+//
+// A value which matches none of the names results in a [FieldParseError] listing the valid names. Note that an
+// [Unmarshaler] implementation on T, or a parser registered for T via [WithTypeParser], takes precedence.
+//
+// RegisterEnum panics if values is empty. Registering T again replaces its existing values.
+func RegisterEnum[T comparable](values map[string]T) {
+	if len(values) == 0 {
+		panic("env: RegisterEnum values is empty")
+	}
+
+	enumType := reflect.TypeOf((*T)(nil)).Elem()
+	names := make(map[string]reflect.Value, len(values))
+	for name, value := range values {
+		names[name] = reflect.ValueOf(value)
+	}
+
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	enums[enumType] = names
+}
+
+// enumParser returns the parser for the enum type t, if it was registered via RegisterEnum.
+func enumParser(t reflect.Type) (fieldSetterFunc, bool) {
+	enumsMu.RLock()
+	names, ok := enums[t]
+	enumsMu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return func(v string) (reflect.Value, error) {
+		value, ok := names[v]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown %s %q, expected one of %v", t, v, sortedKeys(names))
+		}
+		return value, nil
+	}, true
+}
+
+// enumName returns the name v was registered with via RegisterEnum, if its type is a registered enum. An error is
+// returned when v is none of the registered values, as its name could not be unmarshaled.
+func enumName(v reflect.Value) (string, bool, error) {
+	enumsMu.RLock()
+	defer enumsMu.RUnlock()
+
+	names, ok := enums[v.Type()]
+	if !ok {
+		return "", false, nil
+	}
+
+	for name, value := range names {
+		if value.Interface() == v.Interface() {
+			return name, true, nil
+		}
+	}
+
+	return "", true, fmt.Errorf("unregistered %s value %v, expected one of %v", v.Type(), v.Interface(), sortedKeys(names))
+}
@@ -0,0 +1,42 @@
+package env_test
+
+import (
+	"fmt"
+	"github.com/rad12000/go-env"
+)
+
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+)
+
+func (l LogLevel) String() string {
+	return [...]string{"debug", "info", "warn"}[l]
+}
+
+func ExampleRegisterEnum() {
+	env.RegisterEnum(map[string]LogLevel{
+		LevelDebug.String(): LevelDebug,
+		LevelInfo.String():  LevelInfo,
+		LevelWarn.String():  LevelWarn,
+	})
+
+	type config struct {
+		Level     LogLevel
+		Overrides map[string]LogLevel
+	}
+
+	var out config
+	fmt.Println(env.Unmarshal([]string{"LEVEL=warn", "OVERRIDES=http=debug,db=info"}, &out))
+	fmt.Println(out.Level, out.Overrides)
+
+	fmt.Println(env.Unmarshal([]string{"LEVEL=trace"}, &out))
+
+	// Output:
+	// <nil>
+	// warn map[db:info http:debug]
+	// failed to unmarshal environment variables into struct *env_test.config: failed to unmarshal environment variable "LEVEL" into field "Level": unknown env_test.LogLevel "trace", expected one of [debug info warn]
+}
@@ -1,26 +1,130 @@
 package env
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidTarget is returned when the value to unmarshal into is not a non-nil pointer to a struct, or to one of
+// the collections supported by [UnmarshalPrefix].
+var ErrInvalidTarget = errors.New("env: out must be a non-nil pointer to a struct or a supported collection")
+
+// ErrUnknownEnvVars is returned by [UnmarshalPrefixStrict] when environment variables with the prefix do not
+// correspond to any field.
+var ErrUnknownEnvVars = errors.New("env: unknown environment variables")
+
+// ErrDuplicateEnvVars is returned when [WithRejectDuplicates] is provided and the environment being unmarshaled
+// contains the same variable more than once.
+var ErrDuplicateEnvVars = errors.New("env: duplicate environment variables")
+
+// ErrRequired is wrapped by the [FieldParseError] returned when no value is found for a field which requires one,
+// whether via the `required` or `requiredIf` options, or [WithRequireAll].
+var ErrRequired = errors.New("missing required value")
+
+// ErrMaxDepth is wrapped by the [FieldParseError] returned for a nested struct which exceeds the maximum depth set
+// via [WithMaxDepth].
+var ErrMaxDepth = errors.New("maximum nesting depth exceeded")
+
+// ErrUnsupportedType is wrapped by the [FieldParseError] returned for a field whose type cannot be unmarshaled.
+var ErrUnsupportedType = errors.New("unsupported field type")
+
+// requiredIfError is the error for a field whose requiredIf condition is met, which wraps ErrRequired.
+type requiredIfError struct {
+	envVar string
+	value  string
+}
+
+func (e requiredIfError) Error() string {
+	return fmt.Sprintf("missing value required when %s=%s", e.envVar, e.value)
+}
+
+func (e requiredIfError) Unwrap() error {
+	return ErrRequired
+}
+
+// redactedValue replaces the raw value of secret fields in error messages.
+const redactedValue = "[REDACTED]"
 
 type FieldParseError interface {
 	EnvVar() string
 	Field() string
+	// Secret reports whether the field's environment variable was marked as sensitive via the `env:",secret"` tag.
+	// The message returned by Error for a secret environment variable includes neither its raw value nor the message
+	// of the underlying error, which may quote part of the value, but only the kind of error, e.g. invalid syntax.
+	Secret() bool
+	// Value returns the raw value which failed to parse, or which was being resolved when the error occurred.
+	// The value of a secret environment variable is replaced with "[REDACTED]".
+	Value() string
+	// Path returns the segments of the path returned by Field, being the names of fields and the indices or keys of
+	// elements, e.g. Servers[web].Host yields [Servers web Host]. When the error wraps a FieldParseError, such as one
+	// returned by a nested Unmarshaler which itself calls Unmarshal, the segments of its Path are appended, such
+	// that nested locations may be rendered structurally.
+	Path() []string
 	Unwrap() error
 	Error() string
 }
 
-func newFieldParseError(err error, field, envVar string) FieldParseError {
+func newFieldParseError(err error, field, envVar, value string) FieldParseError {
 	return fieldParseError{
 		envVar: envVar,
 		err:    err,
 		field:  field,
+		path:   fieldPathSegments(field, err),
+		value:  value,
 	}
 }
 
+func newSecretFieldParseError(err error, field, envVar, value string) FieldParseError {
+	return fieldParseError{
+		envVar: envVar,
+		err:    err,
+		field:  field,
+		path:   fieldPathSegments(field, err),
+		secret: true,
+		value:  value,
+	}
+}
+
+// fieldPathSegments splits the field path into the names of fields and the keys of elements, followed by the
+// segments of the path of any FieldParseError wrapped by err.
+func fieldPathSegments(field string, err error) []string {
+	var segments []string
+	for field != "" {
+		var segment string
+		if strings.HasPrefix(field, "[") {
+			end := strings.IndexByte(field, ']')
+			if end < 0 {
+				end = len(field)
+			}
+			segment, field = field[1:end], strings.TrimPrefix(field[end:], "]")
+		} else {
+			end := strings.IndexAny(field, ".[")
+			if end < 0 {
+				end = len(field)
+			}
+			segment, field = field[:end], field[end:]
+		}
+
+		segments = append(segments, segment)
+		field = strings.TrimPrefix(field, ".")
+	}
+
+	var inner FieldParseError
+	if errors.As(err, &inner) {
+		segments = append(segments, inner.Path()...)
+	}
+	return segments
+}
+
 type fieldParseError struct {
 	envVar string
 	err    error
 	field  string
+	path   []string
+	secret bool
+	value  string
 }
 
 func (l fieldParseError) EnvVar() string {
@@ -31,10 +135,89 @@ func (l fieldParseError) Field() string {
 	return l.field
 }
 
+func (l fieldParseError) Path() []string {
+	return append([]string(nil), l.path...)
+}
+
+func (l fieldParseError) Secret() bool {
+	return l.secret
+}
+
+func (l fieldParseError) Value() string {
+	if l.secret && l.value != "" {
+		return redactedValue
+	}
+	return l.value
+}
+
 func (l fieldParseError) Unwrap() error {
 	return l.err
 }
 
 func (l fieldParseError) Error() string {
-	return fmt.Sprintf("failed to unmarshal environment variable %q into field %q: %s", l.envVar, l.field, l.err)
+	msg := l.err.Error()
+	if l.secret && l.value != "" {
+		msg = redactedValue + ": " + secretErrorCategory(l.err)
+	}
+
+	return fmt.Sprintf("failed to unmarshal environment variable %q into field %q: %s", l.envVar, l.field, msg)
+}
+
+// secretErrorCategory describes err for a secret field without its message, which may quote the value, or part or
+// a transformation of it, such as a single element or the number preceding a unit.
+func secretErrorCategory(err error) string {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err.Error()
+	}
+
+	for _, sentinel := range []error{ErrRequired, ErrUnsupportedType, ErrMaxDepth} {
+		if errors.Is(err, sentinel) {
+			return sentinel.Error()
+		}
+	}
+
+	return "invalid value"
+}
+
+// AggregateError reports every invalid field at once, and is returned, wrapped, by [ValidateEnv].
+// [errors.As] finds the first of its errors which matches the target, such that a [FieldParseError] may be
+// retrieved from it just as from the error returned by [Unmarshal].
+type AggregateError struct {
+	errs []error
+}
+
+// FieldParseErrors returns the error reported for each invalid field, in the order the fields were processed.
+func (a AggregateError) FieldParseErrors() []FieldParseError {
+	fieldErrs := make([]FieldParseError, 0, len(a.errs))
+	for _, err := range a.errs {
+		var fieldErr FieldParseError
+		if errors.As(err, &fieldErr) {
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+	}
+	return fieldErrs
+}
+
+// Unwrap returns the errors reported for each invalid field.
+func (a AggregateError) Unwrap() []error {
+	return a.errs
+}
+
+// As finds the first error which matches target, as it would be found by [errors.As].
+func (a AggregateError) As(target any) bool {
+	for _, err := range a.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a AggregateError) Error() string {
+	msgs := make([]string, len(a.errs))
+	for i, err := range a.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
 }
@@ -0,0 +1,87 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+type factoryFunc func() (reflect.Value, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[reflect.Type]map[string]factoryFunc)
+)
+
+// RegisterFactory registers a factory for the interface type I, which is invoked to construct the value of
+// a field of type I whenever the field's environment variable is equal to selector.
+//
+// For example, given a field `Backend Backend`, registering a factory for the "redis" selector causes
+// BACKEND=redis to set the field to the value returned by the factory. An error returned by the factory is
+// returned from [Unmarshal] as a [FieldParseError].
+//
+// RegisterFactory panics if I is not an interface type, or if factory is nil.
+// Registering a factory for a selector that is already registered replaces the existing factory.
+func RegisterFactory[I any](selector string, factory func() (I, error)) {
+	ifaceType := reflect.TypeOf((*I)(nil)).Elem()
+	if ifaceType.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("env: RegisterFactory type %s is not an interface", ifaceType))
+	}
+
+	if factory == nil {
+		panic("env: RegisterFactory factory is nil")
+	}
+
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factories[ifaceType] == nil {
+		factories[ifaceType] = make(map[string]factoryFunc)
+	}
+
+	factories[ifaceType][selector] = func() (reflect.Value, error) {
+		v, err := factory()
+		return reflect.ValueOf(&v).Elem(), err
+	}
+}
+
+func hasFactory(ifaceType reflect.Type) bool {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	return len(factories[ifaceType]) > 0
+}
+
+// interfaceSetter sets an interface field to the value constructed by the factory registered for the selector.
+type interfaceSetter struct{}
+
+func (interfaceSetter) Set(v string, field reflect.Value) error {
+	factoriesMu.RLock()
+	selectors := factories[field.Type()]
+	factory, ok := selectors[v]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown %s %q, expected one of %v", field.Type(), v, registeredSelectors(field.Type()))
+	}
+
+	value, err := factory()
+	if err != nil {
+		return err
+	}
+
+	field.Set(value)
+	return nil
+}
+
+func registeredSelectors(ifaceType reflect.Type) []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	selectors := make([]string, 0, len(factories[ifaceType]))
+	for selector := range factories[ifaceType] {
+		selectors = append(selectors, selector)
+	}
+
+	sort.Strings(selectors)
+	return selectors
+}
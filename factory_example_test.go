@@ -0,0 +1,43 @@
+package env_test
+
+import (
+	"fmt"
+	"github.com/rad12000/go-env"
+	"os"
+)
+
+type Backend interface {
+	Name() string
+}
+
+type redisBackend struct{}
+
+func (redisBackend) Name() string {
+	return "redis"
+}
+
+func ExampleRegisterFactory() {
+	env.RegisterFactory("redis", func() (Backend, error) {
+		return redisBackend{}, nil
+	})
+
+	var out struct {
+		Backend Backend
+	}
+
+	revert := Must(SetEnv("BACKEND", "redis"))
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("backend =", out.Backend.Name())
+
+	revertUnknown := Must(SetEnv("BACKEND", "memcached"))
+	defer revertUnknown()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+
+	// Output:
+	// <nil>
+	// backend = redis
+	// failed to unmarshal environment variables into struct *struct { Backend env_test.Backend }: failed to unmarshal environment variable "BACKEND" into field "Backend": unknown env_test.Backend "memcached", expected one of [redis]
+}
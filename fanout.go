@@ -0,0 +1,122 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+)
+
+var (
+	fanoutsMu sync.RWMutex
+	fanouts   = map[string]func(value string) ([]string, error){
+		"hostport": func(value string) ([]string, error) {
+			host, port, err := net.SplitHostPort(value)
+			return []string{host, port}, err
+		},
+	}
+)
+
+// RegisterFanout registers a function, which may be referenced by name via the `fanout` tag option to split the
+// value of a single environment variable into the values of several fields. The fanout is declared on a blank field
+// of the struct containing the fields, which are named in order via the `into` option, e.g.
+//
+//	type Server struct {
+//		_    struct{} `env:"HOST_PORT,fanout=hostport into=Host Port"`
+//		Host string
+//		Port int
+//	}
+//
+// populates Host with localhost and Port with 8080 given HOST_PORT=localhost:8080. Each value returned by fn is
+// parsed into its field just as if it were the field's own environment variable, which takes precedence when set.
+// Hence, defaults and the required option of the fields only apply when neither is set. The fanout "hostport", which
+// splits a value via [net.SplitHostPort], is registered by default.
+//
+// A fanout which is not registered, an error returned by fn, or fn returning a different number of values than
+// fields are named, results in a [FieldParseError] for the blank field.
+//
+// RegisterFanout panics if fn is nil. Registering a function with a name that is already registered replaces the
+// existing function.
+func RegisterFanout(name string, fn func(value string) ([]string, error)) {
+	if fn == nil {
+		panic("env: RegisterFanout fn is nil")
+	}
+
+	fanoutsMu.Lock()
+	defer fanoutsMu.Unlock()
+	fanouts[name] = fn
+}
+
+// fanoutValue is the value a field is populated with via a fanout when its own environment variable is not set.
+type fanoutValue struct {
+	value  string
+	envVar string
+}
+
+// fanoutScope returns s with the values of the fields of the struct type t which are populated via the fanouts the
+// struct declares, keyed by field name.
+func (d *decodeState) fanoutScope(t reflect.Type, s scope) (scope, error) {
+	s.fanout = nil
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i)
+		if fieldType.Name != "_" {
+			continue
+		}
+
+		fTag, _ := parseFieldTag(fieldType.Tag.Get("env"))
+		if fTag.Fanout == "" {
+			continue
+		}
+
+		if fTag.Name == "" {
+			err := errors.New("fanout option requires a variable name")
+			return s, newFieldParseError(err, s.fieldPathPrefix+"_", "", "")
+		}
+
+		envName := fTag.Name
+		d.known[d.fold(envName)] = true
+		value, _, ok := d.lookup(d.source, envName)
+		if !ok {
+			continue
+		}
+
+		values, err := callFanout(fTag.Fanout, value)
+		if err == nil && len(values) != len(fTag.Into) {
+			err = fmt.Errorf("fanout %q returned %d values for %d fields", fTag.Fanout, len(values), len(fTag.Into))
+		}
+
+		for j := 0; err == nil && j < len(values); j++ {
+			if _, ok := t.FieldByName(fTag.Into[j]); !ok {
+				err = fmt.Errorf("fanout field %q does not exist", fTag.Into[j])
+				break
+			}
+
+			if s.fanout == nil {
+				s.fanout = make(map[string]fanoutValue)
+			}
+			s.fanout[fTag.Into[j]] = fanoutValue{value: values[j], envVar: envName}
+		}
+
+		if err != nil {
+			if fTag.Secret {
+				return s, newSecretFieldParseError(err, s.fieldPathPrefix+"_", envName, value)
+			}
+			return s, newFieldParseError(err, s.fieldPathPrefix+"_", envName, value)
+		}
+	}
+
+	return s, nil
+}
+
+func callFanout(name, value string) ([]string, error) {
+	fanoutsMu.RLock()
+	fn, ok := fanouts[name]
+	fanoutsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown fanout %q", name)
+	}
+
+	return fn(value)
+}
@@ -0,0 +1,32 @@
+package env_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rad12000/go-env"
+)
+
+func ExampleRegisterFanout() {
+	env.RegisterFanout("userinfo", func(value string) ([]string, error) {
+		user, password, _ := strings.Cut(value, ":")
+		return []string{user, password}, nil
+	})
+
+	var out struct {
+		_        struct{} `env:"DB_ADDR,fanout=hostport into=Host Port"`
+		_        struct{} `env:"DB_USERINFO,fanout=userinfo into=User Password"`
+		Host     string
+		Port     int
+		User     string
+		Password string `env:",secret"`
+	}
+
+	err := env.Unmarshal([]string{"DB_ADDR=localhost:5432", "DB_USERINFO=admin:hunter2", "PORT=6432"}, &out)
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {_:{} _:{} Host:localhost Port:6432 User:admin Password:hunter2}
+}
@@ -0,0 +1,63 @@
+package env
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnmarshalFanout(t *testing.T) {
+	var out struct {
+		_    struct{} `env:"ADDR,fanout=hostport into=Host Port"`
+		Host string   `env:",required"`
+		Port int      `env:",default=80"`
+		Auth struct {
+			Host string
+		}
+	}
+
+	if err := Unmarshal([]string{"ADDR=[::1]:8080"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Host != "::1" || out.Port != 8080 || out.Auth.Host != "" {
+		t.Fatalf("Expected the values of ADDR, got %+v", out)
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"ADDR=localhost:http"}, &out); !errors.As(err, &fieldErr) ||
+		fieldErr.Field() != "Port" || fieldErr.EnvVar() != "ADDR" {
+		t.Fatalf("Expected a FieldParseError for Port from ADDR, got %v", err)
+	}
+
+	if err := Unmarshal(nil, &out); !errors.Is(err, ErrRequired) {
+		t.Fatalf("Expected ErrRequired without ADDR, got %v", err)
+	}
+
+	tt := []struct {
+		name string
+		addr string
+		out  any
+	}{
+		{"invalid value", "localhost", &out},
+		{"unknown fanout", "localhost:80", &struct {
+			_ struct{} `env:"ADDR,fanout=unknown into=Host"`
+		}{}},
+		{"wrong field count", "localhost:80", &struct {
+			_    struct{} `env:"ADDR,fanout=hostport into=Host"`
+			Host string
+		}{}},
+		{"unknown field", "localhost:80", &struct {
+			_    struct{} `env:"ADDR,fanout=hostport into=Host Port"`
+			Host string
+		}{}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var fieldErr FieldParseError
+			if err := Unmarshal([]string{"ADDR=" + tc.addr}, tc.out); !errors.As(err, &fieldErr) || fieldErr.Field() != "_" || fieldErr.EnvVar() != "ADDR" {
+				t.Fatalf("Expected a FieldParseError for the blank field, got %v", err)
+			}
+		})
+	}
+}
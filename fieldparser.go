@@ -1,9 +1,21 @@
 package env
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type fieldSetterFunc func(v string) (reflect.Value, error)
@@ -22,14 +34,18 @@ func (f fieldSetterFunc) Set(v string, field reflect.Value) error {
 	return nil
 }
 
+// charSliceSetter converts a string to a slice of bytes or runes, such that rune slices hold the decoded UTF-8
+// characters of the string, rather than its individual bytes.
 func charSliceSetter(sliceType reflect.Type) fieldSetterFunc {
 	return func(v string) (reflect.Value, error) {
-		result := reflect.MakeSlice(sliceType, len(v), len(v))
-		strValue := reflect.ValueOf(v)
-		for i := 0; i < len(v); i++ {
-			sliceEl := result.Index(i)
-			char := strValue.Index(i)
-			sliceEl.Set(char.Convert(sliceType.Elem()))
+		chars := reflect.ValueOf([]byte(v))
+		if sliceType.Elem().Kind() == reflect.Int32 {
+			chars = reflect.ValueOf([]rune(v))
+		}
+
+		result := reflect.MakeSlice(sliceType, chars.Len(), chars.Len())
+		for i := 0; i < chars.Len(); i++ {
+			result.Index(i).Set(chars.Index(i).Convert(sliceType.Elem()))
 		}
 		return result, nil
 	}
@@ -80,6 +96,107 @@ var fieldKindToParser = map[reflect.Kind]fieldSetterFunc{
 	},
 }
 
+// fieldTypeToParser contains parsers for specific types which cannot be parsed according to their kind alone.
+// These take precedence over fieldKindToParser.
+var fieldTypeToParser = map[reflect.Type]fieldSetterFunc{
+	reflect.TypeOf((*big.Int)(nil)).Elem(): func(v string) (reflect.Value, error) {
+		i, ok := new(big.Int).SetString(v, 0)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("invalid big.Int value %q", v)
+		}
+		return reflect.ValueOf(i).Elem(), nil
+	},
+	reflect.TypeOf((*big.Float)(nil)).Elem(): func(v string) (reflect.Value, error) {
+		f, ok := new(big.Float).SetString(v)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("invalid big.Float value %q", v)
+		}
+		return reflect.ValueOf(f).Elem(), nil
+	},
+	timeType: func(v string) (reflect.Value, error) {
+		return asReflectValue(time.Parse(time.RFC3339, v))
+	},
+	reflect.TypeOf(time.Duration(0)): func(v string) (reflect.Value, error) {
+		return asReflectValue(time.ParseDuration(v))
+	},
+	reflect.TypeOf(net.IP{}): func(v string) (reflect.Value, error) {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return reflect.Value{}, fmt.Errorf("invalid IP address %q", v)
+		}
+		return reflect.ValueOf(ip), nil
+	},
+	reflect.TypeOf(net.HardwareAddr{}): func(v string) (reflect.Value, error) {
+		return asReflectValue(net.ParseMAC(v))
+	},
+	reflect.TypeOf(net.IPNet{}): func(v string) (reflect.Value, error) {
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(ipNet).Elem(), nil
+	},
+	reflect.TypeOf(url.URL{}): func(v string) (reflect.Value, error) {
+		u, err := url.Parse(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(u).Elem(), nil
+	},
+	// json.RawMessage captures the value verbatim, such that it may be decoded lazily. It is registered explicitly
+	// so that it is never parsed as anything other than the raw bytes of the value, unlike other byte slices.
+	reflect.TypeOf(json.RawMessage{}): func(v string) (reflect.Value, error) {
+		return reflect.ValueOf(json.RawMessage(v)), nil
+	},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// epochParser parses an integer Unix timestamp into a time.Time. The unit must be one of
+// "unix", "unixmilli" or "unixnano".
+func epochParser(unit string) fieldSetterFunc {
+	return func(v string) (reflect.Value, error) {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		switch unit {
+		case "unixmilli":
+			return reflect.ValueOf(time.UnixMilli(n)), nil
+		case "unixnano":
+			return reflect.ValueOf(time.Unix(0, n)), nil
+		default:
+			return reflect.ValueOf(time.Unix(n, 0)), nil
+		}
+	}
+}
+
+// typeParser returns the parser for t, preferring parsers registered via WithTypeParser, then enums registered
+// via RegisterEnum, over those in fieldTypeToParser.
+func (d *decodeState) typeParser(t reflect.Type) (fieldSetterFunc, bool) {
+	if parser, ok := d.opts.typeParsers[t]; ok {
+		return parser, true
+	}
+
+	if parser, ok := enumParser(t); ok {
+		return parser, true
+	}
+
+	parser, ok := fieldTypeToParser[t]
+	return parser, ok
+}
+
+// hasTypeParser reports whether t, or the type t points to, has a parser returned by typeParser.
+func (d *decodeState) hasTypeParser(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	_, ok := d.typeParser(t)
+	return ok
+}
+
 func asReflectValue[T any](v T, err error) (reflect.Value, error) {
 	return reflect.ValueOf(v), err
 }
@@ -89,41 +206,545 @@ func asReflectValueAndCast[C any, T any](v T, err error) (reflect.Value, error)
 	return reflect.ValueOf(v).Convert(reflect.TypeOf(c)), err
 }
 
-func validateFieldAndReturnSetter(field reflect.Value) (fieldSetter, error) {
+// defaultDelimiter separates the elements of slice, array and map values.
+const defaultDelimiter = ","
+
+func (d *decodeState) validateFieldAndReturnSetter(field reflect.Value, tag fieldTag) (fieldSetter, error) {
 	fieldType := field.Type()
 	for fieldType.Kind() == reflect.Pointer {
 		fieldType = fieldType.Elem()
 	}
 
-	if fieldType.Kind() == reflect.Slice {
+	if fieldType == timeType && tag.Epoch != "" {
+		return concreteFieldInitializer{epochParser(tag.Epoch)}, nil
+	}
+
+	if tag.Range {
+		setter, err := d.rangeSetterFor(fieldType)
+		if err != nil {
+			return nil, err
+		}
+		return concreteFieldInitializer{setter}, nil
+	}
+
+	if d.hasTypeParser(fieldType) {
+		return d.scalarSetter(field.Type())
+	}
+
+	if isSQLNull(fieldType) {
+		valueSetter, err := d.scalarSetter(fieldType.Field(0).Type)
+		if err != nil {
+			return nil, unsupportedTypeError(field.Type())
+		}
+
+		return concreteFieldInitializer{sqlNullSetter{valueSetter}}, nil
+	}
+
+	split, err := d.splitter(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Slice:
+		if isUnmarshaler(fieldType.Elem()) {
+			return concreteFieldInitializer{sliceSetter{unmarshalerSetter{d.ctx}, split}}, nil
+		}
+
+		// Slices of bytes and runes hold the characters of the value, unless their elements have their own parser.
 		switch fieldType.Elem().Kind() {
-		case reflect.Int32:
-			return concreteFieldInitializer{charSliceSetter(fieldType)}, nil
-		case reflect.Uint8:
-			return concreteFieldInitializer{charSliceSetter(fieldType)}, nil
+		case reflect.Int32, reflect.Uint8:
+			if !d.hasTypeParser(fieldType.Elem()) {
+				return concreteFieldInitializer{charSliceSetter(fieldType)}, nil
+			}
 		default:
 		}
+
+		elemSetter, err := d.scalarSetter(fieldType.Elem())
+		if err != nil {
+			return nil, unsupportedTypeError(field.Type())
+		}
+
+		return concreteFieldInitializer{sliceSetter{elemSetter, split}}, nil
+	case reflect.Array:
+		elemSetter, err := d.elementSetter(fieldType.Elem())
+		if err != nil {
+			return nil, unsupportedTypeError(field.Type())
+		}
+
+		return concreteFieldInitializer{arraySetter{elemSetter, split}}, nil
+	case reflect.Map:
+		keySetter, err := d.scalarSetter(fieldType.Key())
+		if err != nil {
+			return nil, unsupportedTypeError(field.Type())
+		}
+
+		valueSetter, err := d.elementSetter(fieldType.Elem())
+		if err != nil {
+			return nil, unsupportedTypeError(field.Type())
+		}
+
+		return concreteFieldInitializer{mapSetter{keySetter, valueSetter, split}}, nil
+	case reflect.Interface:
+		if !hasFactory(fieldType) {
+			return nil, unsupportedTypeError(field.Type())
+		}
+
+		return concreteFieldInitializer{interfaceSetter{}}, nil
+	default:
+	}
+
+	setter, err := d.scalarSetter(field.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case fieldType.Kind() == reflect.String:
+		if transform := tagTransform(tag); transform != nil {
+			setter = transformingSetter{setter, transform}
+		}
+	case isNumericKind(fieldType.Kind()):
+		setter = unitSetter(setter, tag, d.opts.numericSeparators)
+	}
+
+	return setter, nil
+}
+
+// isSQLNull reports whether t is one of the database/sql Null types, such as [sql.NullString], which hold a value
+// in their first field alongside a Valid field reporting whether the value is set.
+func isSQLNull(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.PkgPath() != "database/sql" || !strings.HasPrefix(t.Name(), "Null") {
+		return false
+	}
+
+	valid, ok := t.FieldByName("Valid")
+	return ok && t.NumField() == 2 && valid.Index[0] == 1 && valid.Type.Kind() == reflect.Bool
+}
+
+// sqlNullSetter sets the value of a database/sql Null type, marking it as valid.
+type sqlNullSetter struct {
+	next fieldSetter
+}
+
+func (s sqlNullSetter) Set(v string, field reflect.Value) error {
+	if err := s.next.Set(v, field.Field(0)); err != nil {
+		return err
+	}
+
+	field.Field(1).SetBool(true)
+	return nil
+}
+
+// tagTransform returns a function which applies the string transforms specified in a tag, or nil if the tag
+// specifies none.
+func tagTransform(tag fieldTag) func(string) string {
+	if !tag.Trim && !tag.Lower && !tag.Upper {
+		return nil
+	}
+
+	return func(v string) string {
+		if tag.Trim {
+			v = strings.TrimSpace(v)
+		}
+
+		if tag.Lower {
+			v = strings.ToLower(v)
+		}
+
+		if tag.Upper {
+			v = strings.ToUpper(v)
+		}
+
+		return v
+	}
+}
+
+// transformingSetter transforms a value before it is set by next.
+type transformingSetter struct {
+	next      fieldSetter
+	transform func(string) string
+}
+
+func (t transformingSetter) Set(value string, field reflect.Value) error {
+	return t.next.Set(t.transform(value), field)
+}
+
+// unsupportedHandlerSetter sets fields of unsupported types using the handler provided via WithUnsupportedHandler.
+type unsupportedHandlerSetter func(field reflect.Value, value string) error
+
+func (u unsupportedHandlerSetter) Set(v string, field reflect.Value) error {
+	return u(field, v)
+}
+
+// elementSetter returns the setter for the elements of a collection type, which may be any type supported by
+// scalarSetter, or an Unmarshaler.
+func (d *decodeState) elementSetter(t reflect.Type) (fieldSetter, error) {
+	if isUnmarshaler(t) {
+		return unmarshalerSetter{d.ctx}, nil
+	}
+	return d.scalarSetter(t)
+}
+
+// unmarshalerSetter sets a value by invoking its Unmarshaler or ContextUnmarshaler implementation.
+type unmarshalerSetter struct {
+	ctx context.Context
+}
+
+func (u unmarshalerSetter) Set(v string, field reflect.Value) error {
+	_, err := attemptUnmarshal(u.ctx, field, v, true)
+	return err
+}
+
+// scalarSetter returns the setter for a type, or pointer to a type, which has a parser returned by typeParser or
+// whose kind is found in fieldKindToParser.
+func (d *decodeState) scalarSetter(t reflect.Type) (fieldSetter, error) {
+	fieldType := t
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+
+	if parser, ok := d.typeParser(fieldType); ok {
+		return concreteFieldInitializer{parser}, nil
 	}
 
 	parser, ok := fieldKindToParser[fieldType.Kind()]
 	if !ok {
-		return nil, fmt.Errorf("unsupported field type %s", field.Type().Name())
+		return nil, unsupportedTypeError(t)
+	}
+
+	if isIntegerKind(fieldType.Kind()) {
+		parser = overflowParser(parser, fieldType)
+	}
+
+	if fieldType.Kind() == reflect.Bool && d.opts.boolParser != nil {
+		parser = func(v string) (reflect.Value, error) {
+			return asReflectValue(d.opts.boolParser(v))
+		}
+	}
+
+	if isNumericKind(fieldType.Kind()) && d.opts.numericSeparators != "" {
+		parser = stripSeparatorsParser(parser, d.opts.numericSeparators)
+	}
+
+	if (fieldType.Kind() == reflect.Float32 || fieldType.Kind() == reflect.Float64) && d.opts.strictFloats {
+		parser = finiteFloatParser(parser)
+	}
+
+	if fieldType.Kind() == reflect.String && d.opts.unescape {
+		parser = unescapeParser(parser, d.opts.strictUnescape)
 	}
 
 	return concreteFieldInitializer{parser}, nil
 }
 
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	return isNumericKind(k) && k != reflect.Float32 && k != reflect.Float64
+}
+
+// overflowParser replaces the out of range errors returned by next with an overflowError describing the bounds of t.
+func overflowParser(next fieldSetterFunc, t reflect.Type) fieldSetterFunc {
+	return func(v string) (reflect.Value, error) {
+		value, err := next(v)
+		if errors.Is(err, strconv.ErrRange) {
+			return reflect.Value{}, overflowError{value: v, t: t, err: err}
+		}
+		return value, err
+	}
+}
+
+// overflowError reports a value which overflows the integer type it is parsed into, along with the type's bounds.
+type overflowError struct {
+	value string
+	t     reflect.Type
+	err   error
+}
+
+func (e overflowError) Error() string {
+	bits := e.t.Bits()
+	switch e.t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("value %s overflows %s (range 0..%d)", e.value, e.t.Kind(), uint64(1)<<bits-1)
+	default:
+		minimum := int64(-1) << (bits - 1)
+		return fmt.Sprintf("value %s overflows %s (range %d..%d)", e.value, e.t.Kind(), minimum, -(minimum + 1))
+	}
+}
+
+func (e overflowError) Unwrap() error {
+	return e.err
+}
+
+// stripSeparatorsParser removes every occurrence of each character in separators from values before passing them
+// to next.
+func stripSeparatorsParser(next fieldSetterFunc, separators string) fieldSetterFunc {
+	return func(v string) (reflect.Value, error) {
+		return next(stripSeparators(v, separators))
+	}
+}
+
+// stripSeparators removes every occurrence of the runes of separators from v.
+func stripSeparators(v, separators string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(separators, r) {
+			return -1
+		}
+		return r
+	}, v)
+}
+
+// finiteFloatParser rejects the infinite and NaN values parsed by next.
+func finiteFloatParser(next fieldSetterFunc) fieldSetterFunc {
+	return func(v string) (reflect.Value, error) {
+		value, err := next(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		if f := value.Float(); math.IsInf(f, 0) || math.IsNaN(f) {
+			return reflect.Value{}, fmt.Errorf("non-finite float value %q", v)
+		}
+		return value, nil
+	}
+}
+
+// unescapeParser interprets backslash escape sequences in values before passing them to next.
+func unescapeParser(next fieldSetterFunc, strict bool) fieldSetterFunc {
+	return func(v string) (reflect.Value, error) {
+		unescaped, err := unescape(v, strict)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return next(unescaped)
+	}
+}
+
+func unescape(v string, strict bool) (string, error) {
+	if !strings.Contains(v, `\`) {
+		return v, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' {
+			sb.WriteByte(v[i])
+			continue
+		}
+
+		if i == len(v)-1 {
+			if strict {
+				return "", errors.New("invalid trailing backslash")
+			}
+			sb.WriteByte(v[i])
+			continue
+		}
+
+		switch v[i+1] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '\\', '"':
+			sb.WriteByte(v[i+1])
+		default:
+			if strict {
+				return "", fmt.Errorf("invalid escape sequence %q", v[i:i+2])
+			}
+			sb.WriteByte(v[i])
+			continue
+		}
+		i++
+	}
+
+	return sb.String(), nil
+}
+
+func unsupportedTypeError(t reflect.Type) error {
+	return fmt.Errorf("%w %s", ErrUnsupportedType, t)
+}
+
+// splitFunc splits the value of a slice, array or map into its elements.
+type splitFunc func(v string) ([]string, error)
+
+// splitter returns the splitFunc for the delimiter of a field. The function provided via WithSplitFunc replaces
+// splitting on the delimiter when the tag specifies neither a delimiter nor the csv option.
+func (d *decodeState) splitter(tag fieldTag) (splitFunc, error) {
+	delimiter := d.delimiter(tag)
+	if tag.CSV {
+		if utf8.RuneCountInString(delimiter) != 1 {
+			return nil, fmt.Errorf("csv option requires a single character delimiter, got %q", delimiter)
+		}
+
+		return func(v string) ([]string, error) {
+			return splitCSV(v, []rune(delimiter)[0])
+		}, nil
+	}
+
+	split := d.opts.splitFunc
+	if split == nil || tag.Delimiter != "" {
+		split = func(v string) []string {
+			return strings.Split(v, delimiter)
+		}
+	}
+
+	return func(v string) ([]string, error) {
+		if v == "" {
+			return nil, nil
+		}
+		return split(v), nil
+	}, nil
+}
+
+// splitCSV splits a value as a single record of comma separated values, such that elements may contain commas
+// when quoted, e.g. "a,b",c. The comma may be replaced by any other delimiter.
+func splitCSV(v string, comma rune) ([]string, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	r := csv.NewReader(strings.NewReader(v))
+	r.Comma = comma
+	record, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		return nil, errors.New("expected a single CSV record")
+	}
+
+	return record, nil
+}
+
+// sliceSetter splits a value and sets each element of a newly allocated slice.
+type sliceSetter struct {
+	elem  fieldSetter
+	split splitFunc
+}
+
+func (s sliceSetter) Set(v string, field reflect.Value) error {
+	parts, err := s.split(v)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		if err := s.elem.Set(part, result.Index(i)); err != nil {
+			return elementError{strconv.Itoa(i), err}
+		}
+	}
+
+	field.Set(result)
+	return nil
+}
+
+// arraySetter splits a value and sets each element of an array. The number of elements must match the length of
+// the array exactly.
+type arraySetter struct {
+	elem  fieldSetter
+	split splitFunc
+}
+
+func (a arraySetter) Set(v string, field reflect.Value) error {
+	parts, err := a.split(v)
+	if err != nil {
+		return err
+	}
+
+	if len(parts) != field.Len() {
+		return fmt.Errorf("expected %d elements but got %d", field.Len(), len(parts))
+	}
+
+	result := reflect.New(field.Type()).Elem()
+	for i, part := range parts {
+		if err := a.elem.Set(part, result.Index(i)); err != nil {
+			return elementError{strconv.Itoa(i), err}
+		}
+	}
+
+	field.Set(result)
+	return nil
+}
+
+// mapSetter splits a value into key=value pairs, and sets each pair in a newly allocated map.
+type mapSetter struct {
+	key   fieldSetter
+	value fieldSetter
+	split splitFunc
+}
+
+func (m mapSetter) Set(v string, field reflect.Value) error {
+	parts, err := m.split(v)
+	if err != nil {
+		return err
+	}
+
+	mapType := field.Type()
+	result := reflect.MakeMapWithSize(mapType, len(parts))
+	for _, part := range parts {
+		keyVal := strings.SplitN(part, "=", 2)
+		if len(keyVal) != 2 {
+			return fmt.Errorf("invalid map entry %q, expected key=value", part)
+		}
+
+		key := reflect.New(mapType.Key()).Elem()
+		if err := m.key.Set(keyVal[0], key); err != nil {
+			return elementError{keyVal[0], err}
+		}
+
+		value := reflect.New(mapType.Elem()).Elem()
+		if err := m.value.Set(keyVal[1], value); err != nil {
+			return elementError{keyVal[0], err}
+		}
+
+		result.SetMapIndex(key, value)
+	}
+
+	field.Set(result)
+	return nil
+}
+
+// elementError is returned by the setters of collection types when a single element fails to parse, such that
+// the element's index or key may be included in the field path of the resulting FieldParseError.
+type elementError struct {
+	key string
+	err error
+}
+
+func (e elementError) Error() string {
+	return fmt.Sprintf("element %s: %s", e.key, e.err)
+}
+
+func (e elementError) Unwrap() error {
+	return e.err
+}
+
+// concreteFieldInitializer walks any pointers of a field to the value they point to, which is set by next.
+// Nil pointers are allocated, while existing allocations are reused.
 type concreteFieldInitializer struct {
 	next fieldSetter
 }
 
 func (c concreteFieldInitializer) Set(v string, field reflect.Value) error {
-	fieldType := field.Type()
-	for fieldType.Kind() == reflect.Pointer {
-		fieldValue := reflect.New(fieldType.Elem())
-		field.Set(fieldValue)
-		field = fieldValue.Elem()
-		fieldType = fieldValue.Elem().Type()
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
 	}
 
 	return c.next.Set(v, field)
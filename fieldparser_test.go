@@ -0,0 +1,499 @@
+package env
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnescape(t *testing.T) {
+	tt := []struct {
+		value    string
+		strict   bool
+		expected string
+		err      string
+	}{
+		{`plain`, true, "plain", ""},
+		{`line\nbreak\ttab\\slash\"quote\r`, true, "line\nbreak\ttab\\slash\"quote\r", ""},
+		{`C:\path`, false, `C:\path`, ""},
+		{`C:\path`, true, "", `invalid escape sequence "\\p"`},
+		{`trailing\`, false, `trailing\`, ""},
+		{`trailing\`, true, "", "invalid trailing backslash"},
+		{`\\n`, true, `\n`, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := unescape(tc.value, tc.strict)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("Expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if actual != tc.expected {
+				t.Fatalf("Expected %q to equal %q", actual, tc.expected)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUnescape(t *testing.T) {
+	var out struct {
+		Banner string
+		Lines  []string
+		Count  int
+	}
+
+	env := []string{`BANNER=hello\nworld`, `LINES=a\tb,c`, `COUNT=1`}
+	if err := Unmarshal(env, &out, WithUnescape(true)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Banner != "hello\nworld" || out.Lines[0] != "a\tb" {
+		t.Fatalf("Expected values to be unescaped, got %+v", out)
+	}
+
+	if err := Unmarshal(env, &out); err != nil || out.Banner != `hello\nworld` {
+		t.Fatalf("Expected values to be left as-is without WithUnescape, got %+v", out)
+	}
+}
+
+func TestUnmarshalPointersToCollections(t *testing.T) {
+	type config struct {
+		Hosts    *[]string
+		Labels   *map[string]string
+		Ports    **[]int
+		Version  *[2]int
+		Raw      *[]byte
+		Optional *[]string
+	}
+
+	var out config
+	env := []string{"HOSTS=a,b", "LABELS=team=core", "PORTS=80,443", "VERSION=1,2", "RAW=raw"}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Hosts == nil || len(*out.Hosts) != 2 || (*out.Hosts)[1] != "b" {
+		t.Fatalf("Expected Hosts to be [a b], got %v", out.Hosts)
+	}
+
+	if out.Labels == nil || (*out.Labels)["team"] != "core" {
+		t.Fatalf("Expected Labels to be map[team:core], got %v", out.Labels)
+	}
+
+	if out.Ports == nil || *out.Ports == nil || len(**out.Ports) != 2 || (**out.Ports)[1] != 443 {
+		t.Fatalf("Expected Ports to be [80 443], got %v", out.Ports)
+	}
+
+	if out.Version == nil || *out.Version != [2]int{1, 2} {
+		t.Fatalf("Expected Version to be [1 2], got %v", out.Version)
+	}
+
+	if out.Raw == nil || string(*out.Raw) != "raw" {
+		t.Fatalf("Expected Raw to be raw, got %v", out.Raw)
+	}
+
+	if out.Optional != nil {
+		t.Fatalf("Expected Optional to remain nil, got %v", out.Optional)
+	}
+}
+
+func TestUnmarshalStringTransforms(t *testing.T) {
+	var out struct {
+		Env    string  `env:",lower"`
+		Region *string `env:",trim upper"`
+		Level  string  `env:",trim lower oneof=debug info"`
+		Name   string
+	}
+
+	env := []string{"ENV=Production", "REGION= us-east-1\n", "LEVEL=  INFO ", "NAME= Keep "}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Env != "production" || *out.Region != "US-EAST-1" || out.Level != "info" || out.Name != " Keep " {
+		t.Fatalf("Expected values to be transformed, got %+v", out)
+	}
+}
+
+func TestUnmarshalSQLNullTypes(t *testing.T) {
+	var out struct {
+		Name    sql.NullString
+		Retries sql.NullInt64
+		Ratio   *sql.NullFloat64
+		Debug   sql.NullBool
+		Since   sql.NullTime
+		Unset   sql.NullInt32
+	}
+
+	env := []string{"NAME=", "RETRIES=3", "RATIO=0.5", "DEBUG=true", "SINCE=2024-01-02T03:04:05Z"}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !out.Name.Valid || out.Name.String != "" || out.Retries != (sql.NullInt64{Int64: 3, Valid: true}) ||
+		*out.Ratio != (sql.NullFloat64{Float64: 0.5, Valid: true}) || !out.Debug.Bool || !out.Since.Time.Equal(since) {
+		t.Fatalf("Expected values to be set and valid, got %+v", out)
+	}
+
+	if out.Unset.Valid {
+		t.Fatal("Expected a field without a value to remain invalid")
+	}
+
+	var fieldErr FieldParseError
+	err := Unmarshal([]string{"RETRIES=three"}, &out)
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "Retries" {
+		t.Fatalf("Expected a FieldParseError for Retries, got %v", err)
+	}
+}
+
+func TestUnmarshalCharSlicesUTF8(t *testing.T) {
+	var out struct {
+		Runes []rune
+		Bytes []byte
+	}
+
+	if err := Unmarshal([]string{"RUNES=héllo", "BYTES=héllo"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if expected := []rune{'h', 'é', 'l', 'l', 'o'}; string(out.Runes) != string(expected) || len(out.Runes) != 5 {
+		t.Fatalf("Expected runes %q, got %q", expected, out.Runes)
+	}
+
+	if len(out.Bytes) != 6 || string(out.Bytes) != "héllo" {
+		t.Fatalf("Expected 6 bytes of %q, got %q", "héllo", out.Bytes)
+	}
+}
+
+func TestUnmarshalNumericSeparators(t *testing.T) {
+	var out struct {
+		Limit  int
+		Ratio  float64
+		Sizes  []uint
+		Name   string
+		Budget int
+	}
+
+	env := []string{"LIMIT=1_000_000", "RATIO=1,234.5", "SIZES=1_024,2_048", "NAME=a_b,c", "BUDGET=1,000"}
+	if err := Unmarshal(env, &out, WithNumericSeparators("_,")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Limit != 1000000 || out.Ratio != 1234.5 || out.Sizes[1] != 2048 || out.Name != "a_b,c" || out.Budget != 1000 {
+		t.Fatalf("Expected separators to be stripped from numeric values only, got %+v", out)
+	}
+
+	if err := Unmarshal([]string{"LIMIT=1_000"}, &out); err == nil {
+		t.Fatal("Expected an error without WithNumericSeparators")
+	}
+}
+
+func TestUnmarshalParseFloatStrict(t *testing.T) {
+	type config struct {
+		Timeout float64
+		Ratios  []float32
+	}
+
+	var out config
+	if err := Unmarshal([]string{"TIMEOUT=inf", "RATIOS=0.5,NaN"}, &out); err != nil {
+		t.Fatalf("Expected non-finite values to be accepted by default, got %v", err)
+	}
+
+	if err := Unmarshal([]string{"TIMEOUT=1.5", "RATIOS=0.5,1e3"}, &out, WithParseFloatStrict()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, env := range [][]string{{"TIMEOUT=inf"}, {"TIMEOUT=-Infinity"}, {"RATIOS=0.5,NaN"}, {"RATIOS=1e39"}} {
+		var fieldErr FieldParseError
+		if err := Unmarshal(env, &config{}, WithParseFloatStrict()); !errors.As(err, &fieldErr) {
+			t.Fatalf("Expected a FieldParseError for %q, got %v", env, err)
+		}
+	}
+}
+
+func TestUnmarshalOverflow(t *testing.T) {
+	tt := []struct {
+		env      string
+		expected string
+	}{
+		{"SMALL=999", "value 999 overflows int8 (range -128..127)"},
+		{"SMALL=-129", "value -129 overflows int8 (range -128..127)"},
+		{"COUNT=70000", "value 70000 overflows uint16 (range 0..65535)"},
+		{"SIZES=1,99999999999999999999", "value 99999999999999999999 overflows uint64 (range 0..18446744073709551615)"},
+		{"WIDE=9223372036854775808", "value 9223372036854775808 overflows int64 (range -9223372036854775808..9223372036854775807)"},
+	}
+
+	for _, tc := range tt {
+		var out struct {
+			Small int8
+			Count *uint16
+			Sizes []uint64
+			Wide  int64
+		}
+
+		err := Unmarshal([]string{tc.env}, &out)
+		var fieldErr FieldParseError
+		if !errors.As(err, &fieldErr) || !errors.Is(err, strconv.ErrRange) {
+			t.Fatalf("Expected a FieldParseError wrapping strconv.ErrRange for %s, got %v", tc.env, err)
+		}
+
+		if !strings.HasSuffix(err.Error(), tc.expected) {
+			t.Fatalf("Expected error ending in %q, got %q", tc.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalCSV(t *testing.T) {
+	var out struct {
+		Tags   []string          `env:",csv"`
+		Pair   [2]string         `env:",csv"`
+		Labels map[string]string `env:",csv"`
+		Plain  []string
+	}
+
+	env := []string{`TAGS="a,b",c,"say ""hi"""`, `PAIR="x,y",z`, `LABELS="team=core,infra",tier=1`, `PLAIN="a,b",c`}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(out.Tags, []string{"a,b", "c", `say "hi"`}) || out.Pair != [2]string{"x,y", "z"} ||
+		out.Labels["team"] != "core,infra" || len(out.Plain) != 3 {
+		t.Fatalf("Expected values to be split as CSV, got %+v", out)
+	}
+
+	var fieldErr FieldParseError
+	err := Unmarshal([]string{`TAGS="a,b`}, &out)
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "Tags" {
+		t.Fatalf("Expected a FieldParseError for malformed CSV, got %v", err)
+	}
+}
+
+func TestUnmarshalOSPathListSeparator(t *testing.T) {
+	var out struct {
+		SearchPaths []string `env:",ossep"`
+		Quoted      []string `env:",ossep csv"`
+	}
+
+	sep := string(os.PathListSeparator)
+	env := []string{"SEARCH_PATHS=/usr/bin" + sep + "/bin,x", "QUOTED=\"a" + sep + "b\"" + sep + "c"}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(out.SearchPaths, []string{"/usr/bin", "/bin,x"}) || !reflect.DeepEqual(out.Quoted, []string{"a" + sep + "b", "c"}) {
+		t.Fatalf("Expected values to be split on %q, got %+v", sep, out)
+	}
+
+	vars, err := Marshal(out)
+	if err != nil || !reflect.DeepEqual(vars, env) {
+		t.Fatalf("Expected %q, got %q (%v)", env, vars, err)
+	}
+}
+
+func TestUnmarshalDelimiterPrecedence(t *testing.T) {
+	type config struct {
+		Default []string
+		Tagged  []string          `env:",delim=|"`
+		Labels  map[string]string `env:",delim=;"`
+		Quoted  []string          `env:",delim=; csv"`
+	}
+
+	tt := []struct {
+		name     string
+		opts     []Option
+		env      []string
+		expected config
+	}{
+		{
+			name: "built-in comma",
+			env:  []string{"DEFAULT=a,b", "TAGGED=a|b,c", "LABELS=a=1;b=2", `QUOTED="a;b";c`},
+			expected: config{
+				Default: []string{"a", "b"},
+				Tagged:  []string{"a", "b,c"},
+				Labels:  map[string]string{"a": "1", "b": "2"},
+				Quoted:  []string{"a;b", "c"},
+			},
+		},
+		{
+			name: "global option",
+			opts: []Option{WithDelimiter(":")},
+			env:  []string{"DEFAULT=a:b,c", "TAGGED=a|b:c", "LABELS=a=1;b=2", `QUOTED="a;b";c`},
+			expected: config{
+				Default: []string{"a", "b,c"},
+				Tagged:  []string{"a", "b:c"},
+				Labels:  map[string]string{"a": "1", "b": "2"},
+				Quoted:  []string{"a;b", "c"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			if err := Unmarshal(tc.env, &out, tc.opts...); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(out, tc.expected) {
+				t.Fatalf("Expected %+v, got %+v", tc.expected, out)
+			}
+
+			vars, err := Marshal(out, tc.opts...)
+			if err != nil || !reflect.DeepEqual(vars, tc.env) {
+				t.Fatalf("Expected %q, got %q (%v)", tc.env, vars, err)
+			}
+		})
+	}
+
+	var invalid struct {
+		Hosts []string `env:",csv"`
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"HOSTS=a"}, &invalid, WithDelimiter("::")); !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError for a multi-character csv delimiter, got %v", err)
+	}
+}
+
+func TestUnmarshalHardwareAddr(t *testing.T) {
+	type config struct {
+		MAC     net.HardwareAddr
+		Backup  *net.HardwareAddr
+		Bridged []net.HardwareAddr
+	}
+
+	env := []string{"MAC=01:23:45:67:89:ab", "BACKUP=01-23-45-67-89-ac", "BRIDGED=0123.4567.89ad,01:23:45:67:89:ae"}
+
+	var out config
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.MAC.String() != "01:23:45:67:89:ab" || out.Backup.String() != "01:23:45:67:89:ac" ||
+		len(out.Bridged) != 2 || out.Bridged[0].String() != "01:23:45:67:89:ad" {
+		t.Fatalf("Expected addresses to be parsed via net.ParseMAC, got %+v", out)
+	}
+
+	vars, err := Marshal(out)
+	expected := []string{"MAC=01:23:45:67:89:ab", "BACKUP=01:23:45:67:89:ac", "BRIDGED=01:23:45:67:89:ad,01:23:45:67:89:ae"}
+	if err != nil || !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("Expected %q, got %q (%v)", expected, vars, err)
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"BRIDGED=01:23:45:67:89:ad,raw"}, &out); !errors.As(err, &fieldErr) ||
+		fieldErr.Field() != "Bridged[1]" {
+		t.Fatalf("Expected a FieldParseError for Bridged[1], got %v", err)
+	}
+}
+
+func TestUnmarshalPointersToSpecialTypes(t *testing.T) {
+	type config struct {
+		Timeout *time.Duration
+		Since   *time.Time
+		Epoch   *time.Time `env:",unix"`
+		Addr    *net.IP
+		Network *net.IPNet
+		Total   *big.Int
+		Ratio   *big.Float
+		Link    *url.URL
+		Raw     *json.RawMessage
+	}
+
+	env := []string{
+		"TIMEOUT=1m", "SINCE=2024-01-02T03:04:05Z", "EPOCH=1700000000", "ADDR=10.0.0.1", "NETWORK=10.0.0.0/8",
+		"TOTAL=42", "RATIO=0.5", "LINK=https://example.com/path?q=1", `RAW={"a":1}`,
+	}
+
+	var out config
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if *out.Timeout != time.Minute || !out.Since.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		out.Epoch.Unix() != 1700000000 || out.Addr.String() != "10.0.0.1" || out.Network.String() != "10.0.0.0/8" ||
+		out.Total.Int64() != 42 || out.Ratio.String() != "0.5" || out.Link.Host != "example.com" ||
+		string(*out.Raw) != `{"a":1}` {
+		t.Fatalf("Expected every pointer to be allocated and set, got %+v", out)
+	}
+
+	var absent config
+	if err := Unmarshal(nil, &absent); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if absent != (config{}) {
+		t.Fatalf("Expected every pointer to be left nil, got %+v", absent)
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"LINK=http://[::1"}, &absent); !errors.As(err, &fieldErr) || fieldErr.Field() != "Link" {
+		t.Fatalf("Expected a FieldParseError for Link, got %v", err)
+	}
+}
+
+func TestUnmarshalRawMessage(t *testing.T) {
+	var out struct {
+		Payload json.RawMessage
+		Invalid json.RawMessage
+		Pointer *json.RawMessage
+		Items   []json.RawMessage
+	}
+
+	env := []string{`PAYLOAD={"a": [1, 2]}`, "INVALID=aGVsbG8=", `POINTER="x"`, `ITEMS=1,"two"`}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if string(out.Payload) != `{"a": [1, 2]}` || string(out.Invalid) != "aGVsbG8=" || string(*out.Pointer) != `"x"` {
+		t.Fatalf("Expected the values to be captured verbatim, got %+v", out)
+	}
+
+	if !reflect.DeepEqual(out.Items, []json.RawMessage{json.RawMessage("1"), json.RawMessage(`"two"`)}) {
+		t.Fatalf("Expected the elements to be captured verbatim, got %q", out.Items)
+	}
+}
+
+func TestUnmarshalPreallocatedPointers(t *testing.T) {
+	port, retries := 80, 3
+	timeout := &retries
+	out := struct {
+		Port    *int
+		Retries **int
+		Unset   *int
+	}{Port: &port, Retries: &timeout, Unset: &port}
+
+	if err := Unmarshal([]string{"PORT=8080", "RETRIES=5"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != &port || port != 8080 {
+		t.Fatalf("Expected the existing allocation to be set to 8080, got %d at %p", *out.Port, out.Port)
+	}
+
+	if *out.Retries != timeout || retries != 5 {
+		t.Fatalf("Expected the existing allocations to be reused, got %d", **out.Retries)
+	}
+
+	if out.Unset != &port {
+		t.Fatal("Expected a pointer without a value to be left untouched")
+	}
+}
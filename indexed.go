@@ -0,0 +1,51 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// lookupIndexed returns the values of the variables named by prefix followed by contiguous indices starting at 0,
+// e.g. TAG_0 and TAG_1 for the prefix TAG. The first index which is not set terminates the values, such that any
+// greater indices are ignored.
+func (d *decodeState) lookupIndexed(prefix string) []string {
+	prefix = d.joinPrefix(prefix)
+
+	var values []string
+	for i := 0; ; i++ {
+		name := prefix + strconv.Itoa(i)
+		d.known[d.fold(name)] = true
+		value, _, ok := d.lookup(d.source, name)
+		if !ok {
+			return values
+		}
+
+		if d.opts.trimSpace {
+			value = strings.TrimSpace(value)
+		}
+		values = append(values, stripQuotes(value, d.opts.quotePairs))
+	}
+}
+
+// indexedSetter returns a setter which sets a slice field to values, rather than splitting a single value.
+func (d *decodeState) indexedSetter(t reflect.Type, values []string) (fieldSetter, error) {
+	sliceType := t
+	for sliceType.Kind() == reflect.Pointer {
+		sliceType = sliceType.Elem()
+	}
+
+	if sliceType.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("indexed option requires a slice, got %s", t)
+	}
+
+	elemSetter, err := d.elementSetter(sliceType.Elem())
+	if err != nil {
+		return nil, unsupportedTypeError(t)
+	}
+
+	return concreteFieldInitializer{sliceSetter{elemSetter, func(string) ([]string, error) {
+		return values, nil
+	}}}, nil
+}
@@ -0,0 +1,56 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalIndexed(t *testing.T) {
+	type config struct {
+		Tags  []string `env:"TAG,indexed"`
+		Ports []int    `env:",indexed default=80,443"`
+	}
+
+	tt := []struct {
+		name     string
+		env      []string
+		expected config
+	}{
+		{"indexed", []string{"TAG_0=a,b", "TAG_1=c", "PORTS_0=8080"}, config{[]string{"a,b", "c"}, []int{8080}}},
+		{"gap terminates", []string{"TAG_0=a", "TAG_2=c"}, config{[]string{"a"}, []int{80, 443}}},
+		{"not starting at zero", []string{"TAG_1=b"}, config{nil, []int{80, 443}}},
+		{"own variable takes precedence", []string{"TAG=x,y", "TAG_0=a"}, config{[]string{"x", "y"}, []int{80, 443}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			if err := Unmarshal(tc.env, &out); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(out, tc.expected) {
+				t.Fatalf("Expected %+v, got %+v", tc.expected, out)
+			}
+		})
+	}
+
+	vars, err := Marshal(config{Tags: []string{"a,b", "c"}, Ports: []int{8080}})
+	if err != nil || !reflect.DeepEqual(vars, []string{"TAG_0=a,b", "TAG_1=c", "PORTS_0=8080"}) {
+		t.Fatalf("Expected indexed variables, got %q (%v)", vars, err)
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"PORTS_0=80", "PORTS_1=x"}, &config{}); !errors.As(err, &fieldErr) || fieldErr.Field() != "Ports[1]" {
+		t.Fatalf("Expected a FieldParseError for Ports[1], got %v", err)
+	}
+
+	var invalid struct {
+		Tag string `env:",indexed"`
+	}
+
+	if err := Unmarshal([]string{"TAG_0=a"}, &invalid); !errors.As(err, &fieldErr) || fieldErr.Field() != "Tag" {
+		t.Fatalf("Expected a FieldParseError for a non-slice field, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// loadKeyValueStruct populates the fields of the struct field from a single value of key=value pairs, tagged with
+// the `kv` option, e.g. host=localhost port=5432. Keys are matched case-insensitively to the environment variable
+// names the fields would have without any prefix, and their values are parsed just as if they were environment
+// variables. Field errors are returned as is, while errors parsing the pairs themselves are passed to newErr.
+func (d *decodeState) loadKeyValueStruct(
+	field reflect.Value, fTag fieldTag, fieldPath, value string, newErr func(error) error,
+) error {
+	if field.Kind() != reflect.Struct {
+		return newErr(fmt.Errorf("kv option requires a struct, got %s", field.Type()))
+	}
+
+	pairs, err := d.splitKeyValuePairs(value, fTag)
+	if err != nil {
+		return newErr(err)
+	}
+
+	opts := d.opts
+	opts.prefix, opts.caseInsensitive, opts.fallbacks, opts.fileSuffix = "", true, nil, ""
+
+	kv := newDecodeState(d.ctx, pairs, opts)
+	kv.collectErrors = d.collectErrors
+	err = kv.loadEnvVarsIntoStruct(field, scope{fieldPathPrefix: fieldPath + "."})
+	d.populated = append(d.populated, kv.populated...)
+	d.errs = append(d.errs, kv.errs...)
+	if err != nil {
+		return err
+	}
+
+	if unknown := kv.unknownEnvVars(kv.source.(MapSource), ""); fTag.Strict && len(unknown) > 0 {
+		return newErr(fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", ")))
+	}
+
+	return nil
+}
+
+// splitKeyValuePairs splits a value into key=value pairs, separated by the delimiter of the field when specified,
+// and otherwise by commas or white space.
+func (d *decodeState) splitKeyValuePairs(v string, tag fieldTag) (MapSource, error) {
+	var parts []string
+	if tag.Delimiter != "" || d.opts.delimiter != "" {
+		parts = strings.Split(v, d.delimiter(tag))
+	} else {
+		parts = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+	}
+
+	pairs := make(MapSource, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || key == "" {
+			return nil, errors.New("expected key=value pairs")
+		}
+		pairs[key] = value
+	}
+
+	return pairs, nil
+}
+
+// formatKeyValueStruct formats a struct field tagged with the `kv` option as the pairs it is parsed from.
+func (d *decodeState) formatKeyValueStruct(v reflect.Value, tag fieldTag) (string, error) {
+	opts := d.opts
+	opts.prefix = ""
+
+	pairs, err := newDecodeState(d.ctx, MapSource(nil), opts).marshalStruct(v, scope{}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if tag.Delimiter != "" || d.opts.delimiter != "" {
+		return strings.Join(pairs, d.delimiter(tag)), nil
+	}
+	return strings.Join(pairs, " "), nil
+}
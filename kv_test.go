@@ -0,0 +1,73 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dsn struct {
+	Host    string `env:",required"`
+	Port    int    `env:",default=5432"`
+	User    string
+	Timeout time.Duration `env:"connect_timeout"`
+}
+
+func TestUnmarshalKeyValueStruct(t *testing.T) {
+	type config struct {
+		DSN    dsn `env:",kv"`
+		Strict dsn `env:",kv strict delim=;"`
+		Unset  dsn `env:",kv"`
+	}
+
+	env := []string{
+		"DSN=host=localhost, user=admin\tconnect_timeout=5s unknown=1",
+		"STRICT=HOST=db;PORT=6543",
+	}
+
+	var out config
+	populated, err := UnmarshalPopulated(env, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := config{
+		DSN:    dsn{Host: "localhost", Port: 5432, User: "admin", Timeout: 5 * time.Second},
+		Strict: dsn{Host: "db", Port: 6543},
+	}
+	if out != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, out)
+	}
+
+	if !reflect.DeepEqual(populated, []string{"DSN.Host", "DSN.Port", "DSN.User", "DSN.Timeout", "Strict.Host", "Strict.Port"}) {
+		t.Fatalf("Expected the struct's fields to be reported as populated, got %q", populated)
+	}
+
+	vars, err := Marshal(expected)
+	if err != nil || !reflect.DeepEqual(vars[:2], []string{
+		"DSN=HOST=localhost PORT=5432 USER=admin connect_timeout=5s", "STRICT=HOST=db;PORT=6543;USER=;connect_timeout=0s",
+	}) {
+		t.Fatalf("Expected the structs to be marshaled as pairs, got %q (%v)", vars, err)
+	}
+
+	tt := []struct {
+		env   []string
+		field string
+		err   string
+	}{
+		{[]string{"DSN=port=1"}, "DSN.Host", "missing required value"},
+		{[]string{"DSN=host=a port=x"}, "DSN.Port", "invalid syntax"},
+		{[]string{"DSN=host"}, "DSN", "expected key=value pairs"},
+		{[]string{"DSN=host=a", "STRICT=host=a;other=1"}, "Strict", "unknown keys: OTHER"},
+	}
+
+	for _, tc := range tt {
+		var fieldErr FieldParseError
+		err := Unmarshal(tc.env, &config{})
+		if !errors.As(err, &fieldErr) || fieldErr.Field() != tc.field || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("Expected a FieldParseError for %s containing %q, got %v", tc.field, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package env
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Lazy holds a value which is resolved from the environment when first read via [Lazy.Get], rather than when the
+// struct containing it is unmarshaled. This allows values which are expensive to resolve, such as secrets fetched
+// by a remote [Source], to only be resolved when they are used.
+//
+// A Lazy field is resolved exactly as a field of type T with the same name and tag would have been, such that
+// aliases, defaults and the required option all apply, but only when Get is called. Errors, including those for
+// missing required values, are therefore returned by Get rather than by [Unmarshal], as a [FieldParseError].
+//
+// The zero Lazy, such as one which was not unmarshaled into, yields the zero value of T.
+type Lazy[T any] struct {
+	state *lazyState[T]
+}
+
+type lazyState[T any] struct {
+	mu       sync.Mutex
+	resolved bool
+	value    T
+	resolve  func(out reflect.Value) error
+}
+
+// Get returns the value, resolving it on the first call. Once resolved successfully, the value is cached and
+// returned by subsequent calls, while a failed resolution is attempted again on the next call. Get is safe for
+// concurrent use.
+func (l Lazy[T]) Get() (T, error) {
+	if l.state == nil {
+		var zero T
+		return zero, nil
+	}
+
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	if !l.state.resolved {
+		var value T
+		if err := l.state.resolve(reflect.ValueOf(&value).Elem()); err != nil {
+			return value, err
+		}
+		l.state.value, l.state.resolved = value, true
+	}
+
+	return l.state.value, nil
+}
+
+func (l *Lazy[T]) initLazy(resolve func(out reflect.Value) error) {
+	l.state = &lazyState[T]{resolve: resolve}
+}
+
+// lazyInitializer is implemented by pointers to Lazy values.
+type lazyInitializer interface {
+	initLazy(resolve func(out reflect.Value) error)
+}
+
+var lazyInitializerType = reflect.TypeOf((*lazyInitializer)(nil)).Elem()
+
+func isLazy(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(lazyInitializerType)
+}
+
+// initLazy sets a Lazy field to resolve its value by processing a value of its type just as the field itself would
+// have been, against a decodeState of its own, such that values may be resolved concurrently.
+func (d *decodeState) initLazy(field reflect.Value, fieldType reflect.StructField, s scope) {
+	field.Addr().Interface().(lazyInitializer).initLazy(func(out reflect.Value) error {
+		lazy := &decodeState{
+			ctx:       d.ctx,
+			opts:      d.opts,
+			source:    d.source,
+			fallbacks: d.fallbacks,
+			known:     make(map[string]bool),
+			resolved:  make(map[string]string),
+		}
+
+		fieldType.Type = out.Type()
+		return lazy.processField(out, fieldType, s)
+	})
+}
@@ -0,0 +1,68 @@
+package env
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type countingSource struct {
+	MapSource
+	lookups *int32
+}
+
+func (c countingSource) Lookup(name string) (string, bool) {
+	atomic.AddInt32(c.lookups, 1)
+	return c.MapSource.Lookup(name)
+}
+
+func TestUnmarshalLazy(t *testing.T) {
+	var out struct {
+		Name    string
+		Secret  Lazy[string] `env:"API_KEY,required"`
+		Workers Lazy[*int]   `env:",default=4"`
+		Port    Lazy[int]
+	}
+
+	var lookups int32
+	src := countingSource{MapSource{"NAME": "svc", "API_KEY": "key", "PORT": "http"}, &lookups}
+	if err := UnmarshalSource(src, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if lookups != 1 {
+		t.Fatalf("Expected only the eager field to be looked up, got %d lookups", lookups)
+	}
+
+	for i := 0; i < 2; i++ {
+		if secret, err := out.Secret.Get(); err != nil || secret != "key" {
+			t.Fatalf("Expected key, got %q (%v)", secret, err)
+		}
+	}
+
+	if lookups != 2 {
+		t.Fatalf("Expected the lazy value to be cached, got %d lookups", lookups)
+	}
+
+	if workers, err := out.Workers.Get(); err != nil || *workers != 4 {
+		t.Fatalf("Expected the default to apply, got %v (%v)", workers, err)
+	}
+
+	var fieldErr FieldParseError
+	if _, err := out.Port.Get(); !errors.As(err, &fieldErr) || fieldErr.Field() != "Port" {
+		t.Fatalf("Expected a FieldParseError for Port, got %v", err)
+	}
+
+	if err := Unmarshal(nil, &out); err != nil {
+		t.Fatalf("Expected a missing required value not to fail Unmarshal, got %v", err)
+	}
+
+	if _, err := out.Secret.Get(); !errors.Is(err, ErrRequired) {
+		t.Fatalf("Expected ErrRequired, got %v", err)
+	}
+
+	var zero Lazy[string]
+	if value, err := zero.Get(); err != nil || value != "" {
+		t.Fatalf("Expected the zero value, got %q (%v)", value, err)
+	}
+}
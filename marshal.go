@@ -0,0 +1,357 @@
+package env
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshal is the inverse of [Unmarshal], returning the environment variables, formatted as key=value pairs, from
+// which [Unmarshal] would populate a struct identical to in, which must be a struct or a non-nil pointer to one.
+// The variables are named exactly as [Unmarshal] would name them, and are returned in the order of the fields.
+//
+// Values are formatted such that they are parsed back into the same value, for each of the types supported by
+// [Unmarshal], excepting interfaces, types parsed via [WithTypeParser], types whose only support is via
+// [Unmarshaler], and [Lazy] values, none of which can be formatted generically. Such types are supported when they
+// implement [encoding.TextMarshaler], and otherwise result in a [FieldParseError]. Fields tagged with the `fromFile`
+// option also result in a [FieldParseError], as [Unmarshal] reads their value from the file their variable names.
+//
+// Nil pointers, nil interfaces and invalid database/sql Null values are omitted, such that they remain unset.
+// Slice, array and map elements which themselves contain commas can only be round-tripped by fields tagged with the
+// `csv` option.
+func Marshal(in any, opts ...Option) ([]string, error) {
+	value := reflect.ValueOf(in)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: got %T", ErrInvalidTarget, in)
+	}
+
+	d := newDecodeState(context.Background(), MapSource(nil), newOptions(opts))
+	vars, err := d.marshalStruct(value, scope{envVarPrefix: d.joinPrefix(d.opts.prefix)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal struct %T into environment variables: %w", in, err)
+	}
+
+	return vars, nil
+}
+
+// MarshalPrefix is just like [Marshal], but prepends prefix to the generated environment variable names exactly
+// as [UnmarshalPrefix] would, such that the result of MarshalPrefix may be passed to [UnmarshalPrefix] with the same
+// prefix to reproduce in.
+func MarshalPrefix(in any, prefix string, opts ...Option) ([]string, error) {
+	return Marshal(in, append(opts[:len(opts):len(opts)], WithPrefix(prefix))...)
+}
+
+func (d *decodeState) marshalStruct(value reflect.Value, s scope, vars []string) ([]string, error) {
+	// Unexported fields, including embedded structs, may only be read via unsafe when the struct is addressable.
+	if !value.CanAddr() {
+		addressable := reflect.New(value.Type()).Elem()
+		addressable.Set(value)
+		value = addressable
+	}
+
+	structType := value.Type()
+	s = d.structScope(structType, s)
+	for i := 0; i < structType.NumField(); i++ {
+		field, ok := d.structField(value, i)
+		if !ok {
+			continue
+		}
+
+		var err error
+		if vars, err = d.marshalField(field, structType.Field(i), s, vars); err != nil {
+			return nil, err
+		}
+	}
+
+	return vars, nil
+}
+
+func (d *decodeState) marshalField(field reflect.Value, fieldType reflect.StructField, s scope, vars []string) ([]string, error) {
+	fTag, tagErr := parseFieldTag(fieldType.Tag.Get("env"))
+	fieldPath := s.fieldPathPrefix + fieldType.Name
+	if fTag.Name == "-" || d.opts.ignoreFields[fieldPath] {
+		return vars, nil
+	}
+
+	envName := d.fieldEnvName(fieldType, fTag, s)
+	if tagErr != nil {
+		return nil, newFieldParseError(tagErr, fieldPath, envName, "")
+	}
+
+	if fTag.FromFile {
+		err := errors.New("fromFile option is invalid, as the value would be read as the path of a file")
+		return nil, newFieldParseError(err, fieldPath, envName, "")
+	}
+
+	if fTag.JSON {
+		b, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, newFieldParseError(err, fieldPath, envName, "")
+		}
+		return append(vars, envName+"="+string(b)), nil
+	}
+
+	if fTag.Indexed && field.Kind() == reflect.Slice {
+		for i := 0; i < field.Len(); i++ {
+			v, err := d.formatValue(field.Index(i), fieldTag{})
+			if err != nil {
+				return nil, newFieldParseError(err, fmt.Sprintf("%s[%d]", fieldPath, i), envName, "")
+			}
+			vars = append(vars, d.joinPrefix(envName)+strconv.Itoa(i)+"="+v)
+		}
+		return vars, nil
+	}
+
+	if fTag.KV && field.Kind() == reflect.Struct {
+		v, err := d.formatKeyValueStruct(field, fTag)
+		if err != nil {
+			return nil, err
+		}
+		return append(vars, envName+"="+v), nil
+	}
+
+	for field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return vars, nil
+		}
+		field = field.Elem()
+	}
+
+	switch {
+	case d.isStructMap(field.Type()):
+		keys := field.MapKeys()
+		formattedKeys := make(map[string]reflect.Value, len(keys))
+		for _, key := range keys {
+			formatted, err := d.formatValue(key, fieldTag{})
+			if err != nil {
+				return nil, newFieldParseError(err, fieldPath, envName, "")
+			}
+			formattedKeys[formatted] = key
+		}
+
+		for _, key := range sortedKeys(formattedKeys) {
+			elemScope := scope{
+				fieldPathPrefix: fmt.Sprintf("%s[%s].", fieldPath, key),
+				rawNames:        s.rawNames || fTag.Raw,
+				depth:           s.depth + 1,
+				separator:       s.separator,
+			}
+			elemScope.envVarPrefix = d.scopePrefix(d.scopePrefix(envName, s)+key, elemScope)
+
+			if err := d.checkDepth(elemScope); err != nil {
+				return nil, newFieldParseError(err, fieldPath, envName, "")
+			}
+
+			var err error
+			if vars, err = d.marshalStruct(field.MapIndex(formattedKeys[key]), elemScope, vars); err != nil {
+				return nil, err
+			}
+		}
+
+		return vars, nil
+	case d.isNestedStruct(field.Type(), fTag):
+		nested := d.nestedScope(fieldType, fTag, fieldPath, envName, s)
+		if err := d.checkDepth(nested); err != nil {
+			return nil, newFieldParseError(err, fieldPath, envName, "")
+		}
+		return d.marshalStruct(field, nested, vars)
+	case isSQLNull(field.Type()):
+		if !field.Field(1).Bool() {
+			return vars, nil
+		}
+		field = field.Field(0)
+	}
+
+	v, err := d.formatValue(field, fTag)
+	if errors.Is(err, ErrUnsupportedType) && d.opts.skipUnsupported {
+		if d.opts.reportSkipped != nil {
+			d.opts.reportSkipped(newFieldParseError(err, fieldPath, envName, ""))
+		}
+		return vars, nil
+	}
+
+	if err != nil {
+		var elemErr elementError
+		if errors.As(err, &elemErr) {
+			return nil, newFieldParseError(elemErr.err, fmt.Sprintf("%s[%s]", fieldPath, elemErr.key), envName, "")
+		}
+		return nil, newFieldParseError(err, fieldPath, envName, "")
+	}
+
+	return append(vars, envName+"="+v), nil
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isTextMarshaler reports whether t, or a pointer to t, implements encoding.TextMarshaler.
+func isTextMarshaler(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// formatValue formats a value such that it is parsed back into the same value by the setter of its type.
+func (d *decodeState) formatValue(v reflect.Value, tag fieldTag) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType && tag.Epoch != "" {
+		t := v.Interface().(time.Time)
+		switch tag.Epoch {
+		case "unixmilli":
+			return strconv.FormatInt(t.UnixMilli(), 10), nil
+		case "unixnano":
+			return strconv.FormatInt(t.UnixNano(), 10), nil
+		default:
+			return strconv.FormatInt(t.Unix(), 10), nil
+		}
+	}
+
+	if tag.Range && v.Kind() == reflect.Struct {
+		return d.formatRange(v)
+	}
+
+	if name, ok, err := enumName(v); ok {
+		return name, err
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String(), nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	case net.IPNet:
+		return value.String(), nil
+	case net.HardwareAddr:
+		return value.String(), nil
+	case url.URL:
+		return value.String(), nil
+	}
+
+	if text, ok, err := marshalText(v); ok {
+		return text, err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && !d.hasTypeParser(v.Type().Elem()) {
+			switch v.Type().Elem().Kind() {
+			case reflect.Uint8:
+				chars := make([]byte, v.Len())
+				for i := range chars {
+					chars[i] = byte(v.Index(i).Uint())
+				}
+				return string(chars), nil
+			case reflect.Int32:
+				chars := make([]rune, v.Len())
+				for i := range chars {
+					chars[i] = rune(v.Index(i).Int())
+				}
+				return string(chars), nil
+			}
+		}
+
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elem, err := d.formatValue(v.Index(i), fieldTag{})
+			if err != nil {
+				return "", elementError{strconv.Itoa(i), err}
+			}
+			elems[i] = elem
+		}
+		return d.joinValues(elems, tag)
+	case reflect.Map:
+		entries := make(map[string]reflect.Value, v.Len())
+		for _, key := range v.MapKeys() {
+			formatted, err := d.formatValue(key, fieldTag{})
+			if err != nil {
+				return "", err
+			}
+			entries[formatted] = v.MapIndex(key)
+		}
+
+		keys := sortedKeys(entries)
+		elems := make([]string, len(keys))
+		for i, key := range keys {
+			value, err := d.formatValue(entries[key], fieldTag{})
+			if err != nil {
+				return "", elementError{key, err}
+			}
+			elems[i] = key + "=" + value
+		}
+		return d.joinValues(elems, tag)
+	default:
+		return "", unsupportedTypeError(v.Type())
+	}
+}
+
+// marshalText formats v via its encoding.TextMarshaler implementation, reporting whether it has one.
+func marshalText(v reflect.Value) (string, bool, error) {
+	if !isTextMarshaler(v.Type()) {
+		return "", false, nil
+	}
+
+	if !v.Type().Implements(textMarshalerType) {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+
+	text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	return string(text), true, err
+}
+
+// joinValues joins the elements of a slice, array or map, as they would be split by its setter.
+func (d *decodeState) joinValues(elems []string, tag fieldTag) (string, error) {
+	delimiter := d.delimiter(tag)
+	if !tag.CSV {
+		return strings.Join(elems, delimiter), nil
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Comma = []rune(delimiter)[0]
+	if err := w.Write(elems); err != nil {
+		return "", err
+	}
+
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n"), w.Error()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,177 @@
+package env
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type marshalConfig struct {
+	Host     string
+	Port     int `env:"HTTP_PORT"`
+	Debug    bool
+	Ratio    float32
+	Timeout  time.Duration
+	Since    time.Time
+	Epoch    time.Time `env:",unix"`
+	Addr     net.IP
+	Network  net.IPNet
+	Total    *big.Int
+	Hosts    []string
+	Quoted   []string `env:",csv"`
+	Version  [2]uint8
+	Labels   map[string]int
+	Raw      []byte
+	Runes    []rune
+	Name     sql.NullString
+	Unset    sql.NullInt64
+	Optional *string
+	Config   map[string]string `env:",json"`
+	Auth     struct {
+		SigningKey string
+	}
+	Servers map[string]struct{ Port int }
+	Skipped string `env:"-"`
+}
+
+func newMarshalConfig() marshalConfig {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	out := marshalConfig{
+		Host:    "localhost",
+		Port:    8080,
+		Debug:   true,
+		Ratio:   0.1,
+		Timeout: 90 * time.Second,
+		Since:   time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Epoch:   time.Unix(1700000000, 0),
+		Addr:    net.ParseIP("192.168.0.1"),
+		Network: *network,
+		Total:   big.NewInt(12345678901234),
+		Hosts:   []string{"a", "b"},
+		Quoted:  []string{"a,b", `say "hi"`},
+		Version: [2]uint8{1, 2},
+		Labels:  map[string]int{"b": 2, "a": 1},
+		Raw:     []byte("raw"),
+		Runes:   []rune("héllo"),
+		Name:    sql.NullString{String: "name", Valid: true},
+		Config:  map[string]string{"key": "value"},
+		Servers: map[string]struct{ Port int }{"WEB": {Port: 80}, "DB": {Port: 5432}},
+		Skipped: "skipped",
+	}
+	out.Auth.SigningKey = "key"
+	return out
+}
+
+func TestMarshal(t *testing.T) {
+	in := newMarshalConfig()
+	vars, err := Marshal(&in)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{
+		"HOST=localhost",
+		"HTTP_PORT=8080",
+		"DEBUG=true",
+		"RATIO=0.1",
+		"TIMEOUT=1m30s",
+		"SINCE=2024-01-02T03:04:05.000000006Z",
+		"EPOCH=1700000000",
+		"ADDR=192.168.0.1",
+		"NETWORK=10.0.0.0/8",
+		"TOTAL=12345678901234",
+		"HOSTS=a,b",
+		`QUOTED="a,b","say ""hi"""`,
+		"VERSION=1,2",
+		"LABELS=a=1,b=2",
+		"RAW=raw",
+		"RUNES=héllo",
+		"NAME=name",
+		`CONFIG={"key":"value"}`,
+		"AUTH_SIGNING_KEY=key",
+		"SERVERS_DB_PORT=5432",
+		"SERVERS_WEB_PORT=80",
+	}
+
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("Expected %q, got %q", expected, vars)
+	}
+}
+
+func TestMarshalPrefixRoundTrip(t *testing.T) {
+	for _, prefix := range []string{"", "APP", "APP_"} {
+		t.Run(prefix, func(t *testing.T) {
+			in := newMarshalConfig()
+			vars, err := MarshalPrefix(in, prefix)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			var out marshalConfig
+			if err := UnmarshalPrefix(vars, &out, prefix); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			in.Skipped = ""
+			in.Epoch = in.Epoch.Local()
+			out.Epoch = out.Epoch.Local()
+			if !reflect.DeepEqual(in, out) {
+				t.Fatalf("Expected %+v, got %+v", in, out)
+			}
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	if _, err := Marshal(42); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("Expected ErrInvalidTarget, got %v", err)
+	}
+
+	in := struct {
+		Signals []complex64
+	}{Signals: []complex64{1}}
+
+	var fieldErr FieldParseError
+	if _, err := Marshal(in); !errors.As(err, &fieldErr) || fieldErr.Field() != "Signals[0]" {
+		t.Fatalf("Expected a FieldParseError for Signals[0], got %v", err)
+	}
+
+	fromFile := struct {
+		Key string `env:"KEY,fromFile"`
+	}{Key: "contents"}
+
+	if _, err := Marshal(fromFile); !errors.As(err, &fieldErr) || fieldErr.Field() != "Key" {
+		t.Fatalf("Expected a FieldParseError for Key, got %v", err)
+	}
+}
+
+type marshalEnum uint8
+
+func TestMarshalEnum(t *testing.T) {
+	RegisterEnum(map[string]marshalEnum{"low": 1, "high": 2})
+
+	in := struct {
+		Priority marshalEnum
+		Levels   []marshalEnum
+	}{Priority: 2, Levels: []marshalEnum{1, 2}}
+
+	vars, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"PRIORITY=high", "LEVELS=low,high"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("Expected %q, got %q", expected, vars)
+	}
+
+	_, err = Marshal(struct{ Unknown marshalEnum }{Unknown: 3})
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "Unknown" {
+		t.Fatalf("Expected a FieldParseError for Unknown, got %v", err)
+	}
+}
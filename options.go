@@ -0,0 +1,412 @@
+package env
+
+import "reflect"
+
+// Option customizes the behavior of [Unmarshal] and the functions built on top of it.
+type Option func(o *options)
+
+type options struct {
+	prefix             string
+	prefixSeparator    string
+	expandDefaults     bool
+	trimSpace          bool
+	fileSuffix         string
+	typeParsers        map[reflect.Type]fieldSetterFunc
+	unescape           bool
+	strictUnescape     bool
+	fallbacks          []map[string]string
+	deprecationFunc    func(field, used, preferred string)
+	boolParser         func(v string) (bool, error)
+	requireAll         bool
+	caseInsensitive    bool
+	numericSeparators  string
+	keepPresetValues   bool
+	unsupportedHandler func(field reflect.Value, value string) error
+	fieldOverrides     map[string]string
+	ignoreFields       map[string]bool
+	trace              func(fieldPath, envVar string, found bool, source string)
+	splitFunc          func(v string) []string
+	rejectDuplicates   bool
+	quotePairs         [][2]string
+	afterSet           func(fieldPath, envVar string, value reflect.Value, secret bool) error
+	fallbackTag        string
+	delimiter          string
+	allowUnexported    bool
+	emptyAsUnset       bool
+	lowercaseNames     bool
+	skipUnsupported    bool
+	reportSkipped      func(err FieldParseError)
+	maxDepth           int
+	strictFloats       bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		prefixSeparator: "_",
+		maxDepth:        defaultMaxDepth,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithPrefix prepends prefix to field environment variable names, excepting those that are explicitly set
+// via the `env` tag. See [UnmarshalPrefix].
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithPrefixSeparator sets the separator used to join a prefix to the environment variable names it is prepended to,
+// both for the prefix provided via [WithPrefix] and for the names of nested structs. The separator is only added
+// when the prefix does not already end with it. Defaults to "_". A nested struct may override the separator for its
+// own fields via the `childsep` tag option.
+func WithPrefixSeparator(sep string) Option {
+	return func(o *options) {
+		o.prefixSeparator = sep
+	}
+}
+
+// WithDefaultTagExpansion enables the expansion of references to other fields within `env:",default="` tags,
+// e.g. `env:",default=localhost:${PORT}"`. A reference is the environment variable name of a field, and expands
+// to the value that field was populated with, either from the environment or from its own default. Because fields
+// are processed in order, only fields declared before the default may be referenced. Referencing a field which
+// has not been populated is an error.
+func WithDefaultTagExpansion() Option {
+	return func(o *options) {
+		o.expandDefaults = true
+	}
+}
+
+// WithTrimSpace removes leading and trailing white space from every value before it is parsed, including values
+// provided via the `env:",default="` tag.
+func WithTrimSpace() Option {
+	return func(o *options) {
+		o.trimSpace = true
+	}
+}
+
+// WithFileSuffix enables reading a field's value from a file when its environment variable is not set, but an
+// environment variable of the same name followed by suffix is, e.g. DB_PASSWORD_FILE for the suffix "_FILE".
+// The value of the suffixed environment variable is the path of the file to read.
+//
+// A value set directly via the environment variable takes precedence over one read from a file, which in turn
+// takes precedence over the `env:",default="` tag.
+func WithFileSuffix(suffix string) Option {
+	return func(o *options) {
+		o.fileSuffix = suffix
+	}
+}
+
+// WithTypeParser registers a parser for fields of type T, or pointers to T, which takes precedence over the
+// built-in parsing of T. It is also used for the elements of slices, arrays and maps of T.
+// When T is itself a pointer type, the parser is used for the type T points to, so that parsers such as
+// [net/mail.ParseAddress] may be registered directly.
+// Note that an [Unmarshaler] implementation on T takes precedence over the parser.
+func WithTypeParser[T any](parse func(v string) (T, error)) Option {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	depth := 0
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		depth++
+	}
+
+	return func(o *options) {
+		if o.typeParsers == nil {
+			o.typeParsers = make(map[reflect.Type]fieldSetterFunc)
+		}
+
+		o.typeParsers[t] = func(v string) (reflect.Value, error) {
+			parsed, err := parse(v)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			value := reflect.ValueOf(&parsed).Elem()
+			for i := 0; i < depth; i++ {
+				if value.IsNil() {
+					return reflect.Zero(t), nil
+				}
+				value = value.Elem()
+			}
+			return value, nil
+		}
+	}
+}
+
+// WithUnescape interprets the backslash escape sequences \n, \r, \t, \\ and \" within values assigned to string
+// fields, including the elements of string slices, arrays and maps. When strict is true, any other backslash
+// sequence is an error; otherwise it is left as-is.
+func WithUnescape(strict bool) Option {
+	return func(o *options) {
+		o.unescape = true
+		o.strictUnescape = strict
+	}
+}
+
+// WithFallback provides environment variables which are used when a field's environment variable, or any of its
+// aliases, is not set in the environment being unmarshaled. When provided more than once, fallbacks are consulted
+// in the order they were provided.
+//
+// The precedence for resolving a field's value is therefore: the environment being unmarshaled (including
+// [WithFileSuffix]), then each fallback, then the `env:",default="` tag.
+func WithFallback(vars map[string]string) Option {
+	return func(o *options) {
+		o.fallbacks = append(o.fallbacks, vars)
+	}
+}
+
+// WithDeprecationFunc registers a callback which is invoked whenever a field's value is read from one of the
+// aliases specified via the `env:",alias="` tag, rather than from its preferred name. The callback receives the
+// path of the field (e.g. "Auth.SigningKey"), the alias that was used, and the preferred name, such that
+// a migration warning may be logged.
+func WithDeprecationFunc(fn func(field, used, preferred string)) Option {
+	return func(o *options) {
+		o.deprecationFunc = fn
+	}
+}
+
+// WithBoolParser replaces the parsing of every bool field, including the elements of bool slices, arrays and maps,
+// which defaults to [strconv.ParseBool].
+func WithBoolParser(parse func(v string) (bool, error)) Option {
+	return func(o *options) {
+		o.boolParser = parse
+	}
+}
+
+// WithRequireAll treats every field as though it were tagged with the `required` option, excepting pointer fields,
+// fields with a default, and fields tagged with the `xor` option. Combined with [ValidateEnv], this reports every
+// missing environment variable at once.
+func WithRequireAll() Option {
+	return func(o *options) {
+		o.requireAll = true
+	}
+}
+
+// WithCaseInsensitive matches environment variable names regardless of case, including any prefix, such that a field
+// named Port with the prefix "app" is populated from any of APP_PORT, app_port or App_Port. When several variables
+// differ only by case, which of them is used is unspecified. Names are looked up in upper case in any [Source]
+// other than a [MapSource].
+func WithCaseInsensitive() Option {
+	return func(o *options) {
+		o.caseInsensitive = true
+	}
+}
+
+// WithNumericSeparators strips every occurrence of each character in separators from the values of integer and
+// float fields before they are parsed, allowing human-friendly values such as 1_000_000 or 1,000 when separators
+// is "_,". Since the elements of slices, arrays and maps are split on commas before they are parsed, commas cannot
+// be used as separators within their elements.
+func WithNumericSeparators(separators string) Option {
+	return func(o *options) {
+		o.numericSeparators = separators
+	}
+}
+
+// WithKeepPresetValues gives precedence to the values fields hold prior to unmarshaling over the `env:",default="`
+// and `env:",defaultFunc="` tags, such that a default is only applied to a field still holding its zero value.
+// A preset value also satisfies the `required` and `requiredIf` options. Values found in the environment always take
+// precedence over preset values.
+func WithKeepPresetValues() Option {
+	return func(o *options) {
+		o.keepPresetValues = true
+	}
+}
+
+// WithUnsupportedHandler provides a last resort for setting fields whose type is not otherwise supported, which
+// would result in an unsupported field type error. The handler is only invoked when a value is found for the field,
+// and is passed the field itself, such that it must set the field. An error returned by the handler results in a
+// [FieldParseError].
+func WithUnsupportedHandler(handler func(field reflect.Value, value string) error) Option {
+	return func(o *options) {
+		o.unsupportedHandler = handler
+	}
+}
+
+// WithFieldOverrides overrides the environment variable names of fields, keyed by their paths (e.g. "Auth.SigningKey"),
+// taking precedence over names computed from the field or provided via the `env` tag. This allows variables to be
+// remapped when neither the struct tags nor the environment can be changed. Overriding the name of a nested struct
+// changes the prefix of its fields. When provided more than once, the overrides are merged.
+func WithFieldOverrides(overrides map[string]string) Option {
+	return func(o *options) {
+		if o.fieldOverrides == nil {
+			o.fieldOverrides = make(map[string]string, len(overrides))
+		}
+
+		for path, name := range overrides {
+			o.fieldOverrides[path] = name
+		}
+	}
+}
+
+// WithIgnoreFields skips the fields with the given paths (e.g. "Auth.SigningKey"), just as if they were tagged with
+// `env:"-"`, leaving them untouched. Ignoring a nested struct skips all of its fields.
+func WithIgnoreFields(paths ...string) Option {
+	return func(o *options) {
+		if o.ignoreFields == nil {
+			o.ignoreFields = make(map[string]bool, len(paths))
+		}
+
+		for _, path := range paths {
+			o.ignoreFields[path] = true
+		}
+	}
+}
+
+// WithTrace invokes trace for every field as its value is resolved, allowing the reason a field ended up with its
+// value to be logged. The fields of nested structs are reported individually, rather than the structs themselves.
+//
+// The envVar is the name of the environment variable the value was read from, or the field's own environment
+// variable name when no value was found. The source is one of "env", "alias", "file" (see [WithFileSuffix]),
+// "fallback" (see [WithFallback]), "fanout" (see [RegisterFanout]), "defaultFrom", "default", "defaultFunc" or
+// "defaults" (see [UnmarshalWithDefaults]), or is empty when no value was found.
+func WithTrace(trace func(fieldPath, envVar string, found bool, source string)) Option {
+	return func(o *options) {
+		o.trace = trace
+	}
+}
+
+// WithSplitFunc replaces splitting the values of slice, array and map fields on commas with split, allowing arbitrary
+// tokenization, e.g. via a regular expression. Each element returned by split is parsed just as it would be otherwise.
+// Empty values are never passed to split, and always result in no elements. Fields tagged with the `csv`, `ossep` or
+// `delim` options continue to be split according to their tags. Note that [Marshal] still joins elements with the
+// delimiter, a comma unless set via [WithDelimiter].
+func WithSplitFunc(split func(v string) []string) Option {
+	return func(o *options) {
+		o.splitFunc = split
+	}
+}
+
+// WithRejectDuplicates returns an error wrapping [ErrDuplicateEnvVars] when the environment being unmarshaled contains
+// the same variable more than once, e.g. because several env files were concatenated. By default, the last value
+// wins, just as it does for [os.Environ]. Names differing only by case are duplicates when [WithCaseInsensitive] is
+// provided. Only environments provided as key=value pairs, rather than via a [Source], may contain duplicates.
+func WithRejectDuplicates() Option {
+	return func(o *options) {
+		o.rejectDuplicates = true
+	}
+}
+
+// WithQuoteStripping removes a single layer of matching quotes from every value before it is parsed, including
+// values provided via the `env:",default="` tag, for sources which over-quote values. Each pair is an opening and a
+// closing quote, such as {"`", "`"}, and only the first pair which both opens and closes a value is removed, such
+// that "'a'" becomes 'a'. When no pairs are provided, double and single quotes are removed. Quotes are removed after
+// white space is trimmed via [WithTrimSpace].
+func WithQuoteStripping(pairs ...[2]string) Option {
+	if len(pairs) == 0 {
+		pairs = [][2]string{{`"`, `"`}, {"'", "'"}}
+	}
+
+	return func(o *options) {
+		o.quotePairs = pairs
+	}
+}
+
+// WithAfterSet invokes hook after each field is successfully set from a value, whether found in the environment or
+// provided by a default, such that the applied configuration may be logged or counted. The hook receives the path of
+// the field (e.g. "Auth.SigningKey"), the name of the environment variable the value was read from, the field itself,
+// and whether it was tagged with the `secret` option, in which case its value should be masked. Fields left untouched
+// are not reported. An error returned by the hook aborts unmarshaling with a [FieldParseError] wrapping it.
+func WithAfterSet(hook func(fieldPath, envVar string, value reflect.Value, secret bool) error) Option {
+	return func(o *options) {
+		o.afterSet = hook
+	}
+}
+
+// WithFallbackTag derives the environment variable names of fields without a name in their `env` tag from the tag
+// with the given key, such as "json", before falling back to the names of the fields themselves. Any options
+// following the name, such as ",omitempty", are ignored, as is a name of "-". The name is converted and prefixed just
+// like a field name, such that `json:"signingKey,omitempty"` yields SIGNING_KEY, allowing existing API structs to
+// double as configuration without duplicate tags.
+func WithFallbackTag(key string) Option {
+	return func(o *options) {
+		o.fallbackTag = key
+	}
+}
+
+// WithDelimiter sets the separator of the elements of every slice, array and map field, in place of a comma. A field
+// may still specify its own delimiter via the `delim` tag option, which takes precedence, e.g. `env:"HOSTS,delim=;"`.
+// Note that [WithSplitFunc] takes precedence over the delimiter.
+func WithDelimiter(delimiter string) Option {
+	return func(o *options) {
+		o.delimiter = delimiter
+	}
+}
+
+// WithAllowUnexported populates unexported fields too, which are otherwise skipped, saving exporting the fields of
+// internal configuration structs purely so that they may be loaded. It applies equally to [Describe] and [Marshal].
+//
+// This option is unsafe: unexported fields are set via the unsafe package, bypassing the guarantees of the
+// language that only the package declaring a type may modify its unexported fields. Only use it for structs owned
+// by the calling package.
+func WithAllowUnexported() Option {
+	return func(o *options) {
+		o.allowUnexported = true
+	}
+}
+
+// WithTreatEmptyAsUnset treats environment variables set to the empty string as though they were not set at all,
+// such that aliases, fallbacks and defaults apply, required fields report a missing value, and pointer fields are
+// left nil. This allows a variable to be cleared by tools which cannot unset it. By default, an empty value is
+// parsed like any other, which is an error for most types other than strings. Fields which legitimately accept an
+// empty value may opt out via the `allowEmpty` tag option, e.g. `env:"SUFFIX,allowEmpty"`.
+func WithTreatEmptyAsUnset() Option {
+	return func(o *options) {
+		o.emptyAsUnset = true
+	}
+}
+
+// WithLowercaseNames constructs lower case environment variable names from the names of fields, e.g. SigningKey
+// yields signing_key, for environments with lower case conventions. Underscores are inserted just as they are
+// otherwise. Names provided via the `env` tag or [WithFieldOverrides], and any prefix, are used as is.
+func WithLowercaseNames() Option {
+	return func(o *options) {
+		o.lowercaseNames = true
+	}
+}
+
+// WithSkipUnsupported silently skips fields whose type is not supported, such as channels and functions, rather than
+// returning an error, just as if they were tagged with `env:"-"`. This allows structs containing fields which are not
+// configuration, such as those embedded from third-party packages, to be unmarshaled. Fields are skipped whether or
+// not a value is found for them. Note that a handler provided via [WithUnsupportedHandler] takes precedence.
+func WithSkipUnsupported() Option {
+	return func(o *options) {
+		o.skipUnsupported = true
+	}
+}
+
+// WithParseFloatStrict rejects the non-finite values accepted by [strconv.ParseFloat], such as inf and nan, when
+// parsing float32 and float64 fields, returning a [FieldParseError] instead. These are rarely valid configuration,
+// such that e.g. TIMEOUT_SECONDS=inf is more likely a mistake than an intentionally unbounded timeout.
+func WithParseFloatStrict() Option {
+	return func(o *options) {
+		o.strictFloats = true
+	}
+}
+
+// WithSkipUnsupportedFunc is just like [WithSkipUnsupported], but additionally invokes report for every field which
+// is skipped, with the [FieldParseError], wrapping [ErrUnsupportedType], which would otherwise have been returned.
+// This allows skipped fields to be logged, or collected and treated as fatal at the caller's discretion, e.g. while
+// gradually adding fields without breaking existing deployments.
+func WithSkipUnsupportedFunc(report func(err FieldParseError)) Option {
+	return func(o *options) {
+		o.skipUnsupported = true
+		o.reportSkipped = report
+	}
+}
+
+// defaultMaxDepth is the maximum depth of nested structs, unless set via WithMaxDepth.
+const defaultMaxDepth = 32
+
+// WithMaxDepth sets the maximum depth of nested structs, including the struct values of maps, which defaults to 32.
+// The fields of the struct being unmarshaled are at depth 0, those of the structs nested within it at depth 1, and
+// so on. A nested struct beyond the maximum depth results in a [FieldParseError] wrapping [ErrMaxDepth], naming the
+// field at which unmarshaling stopped. This guards against unbounded recursion when processing externally-defined
+// types, such as a struct containing a map of itself.
+func WithMaxDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = depth
+	}
+}
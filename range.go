@@ -0,0 +1,104 @@
+package env
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+)
+
+// rangeSeparator separates the bounds of values of fields tagged with the `range` option, e.g. 8000-8100.
+const rangeSeparator = "-"
+
+// rangeSetter parses a value of the form min-max into a struct with exactly two exported fields of the same type,
+// the first of which is set to min and the second to max.
+type rangeSetter struct {
+	bound fieldSetter
+}
+
+// rangeSetterFor returns the rangeSetter for the struct type t, or an error if t is not a valid range type.
+func (d *decodeState) rangeSetterFor(t reflect.Type) (fieldSetter, error) {
+	if t.Kind() != reflect.Struct || t.NumField() != 2 || !t.Field(0).IsExported() || !t.Field(1).IsExported() ||
+		t.Field(0).Type != t.Field(1).Type {
+		return nil, fmt.Errorf("range option requires a struct with two exported fields of the same type, got %s", t)
+	}
+
+	bound, err := d.scalarSetter(t.Field(0).Type)
+	if err != nil {
+		return nil, unsupportedTypeError(t)
+	}
+
+	return rangeSetter{bound}, nil
+}
+
+func (r rangeSetter) Set(v string, field reflect.Value) error {
+	// The separator is searched for after the first character, such that the minimum may be negative.
+	i := -1
+	if len(v) > 0 {
+		i = strings.Index(v[1:], rangeSeparator)
+	}
+
+	if i < 0 {
+		return fmt.Errorf("invalid range %q, expected min%smax", v, rangeSeparator)
+	}
+
+	minValue, maxValue := v[:i+1], v[i+1+len(rangeSeparator):]
+	result := reflect.New(field.Type()).Elem()
+	if err := r.bound.Set(minValue, result.Field(0)); err != nil {
+		return err
+	}
+
+	if err := r.bound.Set(maxValue, result.Field(1)); err != nil {
+		return err
+	}
+
+	if isInverted(result.Field(0), result.Field(1)) {
+		return errors.New("invalid range, the minimum is greater than the maximum")
+	}
+
+	field.Set(result)
+	return nil
+}
+
+// isInverted reports whether low is greater than high, for numeric and IP address bounds. Bounds of any other type
+// are never inverted.
+func isInverted(low, high reflect.Value) bool {
+	for low.Kind() == reflect.Pointer {
+		if low.IsNil() || high.IsNil() {
+			return false
+		}
+		low, high = low.Elem(), high.Elem()
+	}
+
+	switch low.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return low.Int() > high.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return low.Uint() > high.Uint()
+	case reflect.Float32, reflect.Float64:
+		return low.Float() > high.Float()
+	}
+
+	if lowIP, ok := low.Interface().(net.IP); ok {
+		return bytes.Compare(lowIP.To16(), high.Interface().(net.IP).To16()) > 0
+	}
+
+	return false
+}
+
+// formatRange formats the bounds of a struct populated by rangeSetter.
+func (d *decodeState) formatRange(v reflect.Value) (string, error) {
+	minValue, err := d.formatValue(v.Field(0), fieldTag{})
+	if err != nil {
+		return "", err
+	}
+
+	maxValue, err := d.formatValue(v.Field(1), fieldTag{})
+	if err != nil {
+		return "", err
+	}
+
+	return minValue + rangeSeparator + maxValue, nil
+}
@@ -0,0 +1,75 @@
+package env
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type portRange struct {
+	Min, Max int
+}
+
+type ipRange struct {
+	Start, End net.IP
+}
+
+func TestUnmarshalRange(t *testing.T) {
+	type config struct {
+		Ports   portRange `env:",range"`
+		Offsets struct {
+			Low, High float64
+		} `env:",range"`
+		Addresses ipRange    `env:",range"`
+		Optional  *portRange `env:",range"`
+	}
+
+	env := []string{"PORTS=8000-8100", "OFFSETS=-1.5--0.5", "ADDRESSES=10.0.0.1-10.0.0.9", "OPTIONAL=1-1"}
+
+	var out config
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Ports != (portRange{8000, 8100}) || out.Offsets.Low != -1.5 || out.Offsets.High != -0.5 ||
+		out.Addresses.Start.String() != "10.0.0.1" || out.Addresses.End.String() != "10.0.0.9" ||
+		*out.Optional != (portRange{1, 1}) {
+		t.Fatalf("Expected the ranges to be parsed, got %+v", out)
+	}
+
+	vars, err := Marshal(out)
+	if err != nil || !reflect.DeepEqual(vars, env) {
+		t.Fatalf("Expected %q, got %q (%v)", env, vars, err)
+	}
+
+	tt := []struct {
+		env []string
+		err string
+	}{
+		{[]string{"PORTS=8100-8000"}, "minimum is greater than the maximum"},
+		{[]string{"ADDRESSES=10.0.0.9-10.0.0.1"}, "minimum is greater than the maximum"},
+		{[]string{"PORTS=8000"}, "invalid range"},
+		{[]string{"PORTS=a-b"}, "invalid syntax"},
+	}
+
+	for _, tc := range tt {
+		var fieldErr FieldParseError
+		err := Unmarshal(tc.env, &config{})
+		if !errors.As(err, &fieldErr) || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("Expected a FieldParseError containing %q for %q, got %v", tc.err, tc.env, err)
+		}
+	}
+
+	var invalid struct {
+		Bounds struct {
+			Min int
+			Max uint
+		} `env:",range"`
+	}
+
+	if err := Unmarshal([]string{"BOUNDS=1-2"}, &invalid); err == nil || !strings.Contains(err.Error(), "two exported fields of the same type") {
+		t.Fatalf("Expected an error for an invalid range type, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package env
+
+import "os"
+
+// Source provides the values of environment variables by name, allowing values to be looked up on demand from
+// stores such as Consul or Vault, rather than being materialized up front.
+type Source interface {
+	// Lookup returns the value of the variable with the given name, and whether it was found.
+	Lookup(name string) (string, bool)
+}
+
+// MapSource is a Source backed by a map of variable names to values.
+type MapSource map[string]string
+
+// Lookup returns the value of the named variable in the map.
+func (m MapSource) Lookup(name string) (string, bool) {
+	value, ok := m[name]
+	return value, ok
+}
+
+// OSSource is a Source backed by the environment of the current process, as read by [os.LookupEnv].
+type OSSource struct{}
+
+// Lookup returns the value of the named variable in the environment of the current process.
+func (OSSource) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// UnmarshalSource is just like [Unmarshal], but looks up the value of each field's environment variable in src.
+func UnmarshalSource(src Source, out any, opts ...Option) error {
+	return NewDecoder(opts...).DecodeSource(src, out)
+}
@@ -0,0 +1,44 @@
+package env_test
+
+import (
+	"fmt"
+	"github.com/rad12000/go-env"
+	"strings"
+)
+
+// vaultSource looks up secrets on demand, rather than reading every secret up front.
+type vaultSource struct {
+	secrets map[string]string
+	lookups []string
+}
+
+func (v *vaultSource) Lookup(name string) (string, bool) {
+	v.lookups = append(v.lookups, name)
+	value, ok := v.secrets["secret/app/"+strings.ToLower(name)]
+	return value, ok
+}
+
+func ExampleUnmarshalSource() {
+	src := &vaultSource{secrets: map[string]string{"secret/app/db_password": "hunter2"}}
+
+	var out struct {
+		DBPassword string `env:"DB_PASSWORD"`
+		Port       int    `env:",default=8080"`
+	}
+
+	err := env.UnmarshalSource(src, &out)
+	fmt.Println(err)
+	fmt.Printf("%+v\n", out)
+	fmt.Println(src.lookups)
+
+	err = env.UnmarshalSource(env.MapSource{"PORT": "9090"}, &out)
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {DBPassword:hunter2 Port:8080}
+	// [DB_PASSWORD PORT]
+	// <nil>
+	// {DBPassword:hunter2 Port:9090}
+}
@@ -0,0 +1,154 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// isStructMap reports whether a field of type t is a map whose values are structs populated from the environment,
+// e.g. map[string]ServerConfig.
+func (d *decodeState) isStructMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && d.isNestedStruct(t.Elem(), fieldTag{})
+}
+
+// loadStructMap populates a map with struct values from the environment variables beginning with prefix, where the
+// segment of each name following the prefix and preceding the name of a struct field is the map key.
+// For example, given the prefix SERVERS_, the variables SERVERS_WEB_HOST and SERVERS_DB_HOST populate the Host field
+// of the values at the keys WEB and DB.
+//
+// A key may itself contain the prefix separator (e.g. SERVERS_EU_WEST_HOST has the key EU_WEST). When the remainder
+// of a name ends with the names of several struct fields, the longest field name is matched, such that keys are as
+// short as possible. Likewise, a struct map nested within the struct values has its own keys excluded, such that
+// SERVERS_WEB_ROUTES_API_PATH has the key WEB when the struct values have a Routes field which is a struct map.
+//
+// The map is left untouched when no variables match, in which case false is returned. Errors parsing keys are
+// passed to newErr, while errors populating the struct values are returned as is.
+func (d *decodeState) loadStructMap(
+	field reflect.Value, fTag fieldTag, fieldPath, prefix string, s scope, newErr func(error) error,
+) (bool, error) {
+	prefix = d.scopePrefix(prefix, s)
+	var (
+		mapType   = field.Type()
+		elemScope = scope{rawNames: s.rawNames || fTag.Raw, depth: s.depth + 1, separator: s.separator}
+		suffixes  []string
+		// mapNames are the names of the struct maps nested within the struct values, which precede their own keys.
+		mapNames []string
+	)
+
+	for _, info := range d.describeStruct(mapType.Elem(), elemScope, nil) {
+		if d.isStructMap(fieldTypeByPath(mapType.Elem(), info.Field)) {
+			mapNames = append(mapNames, d.fold(info.EnvVar))
+			continue
+		}
+		suffixes = append(suffixes, d.fold(info.EnvVar))
+	}
+
+	keys := d.structMapKeys(d.fold(prefix), suffixes, mapNames, s)
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	if err := d.checkDepth(elemScope); err != nil {
+		return false, newErr(err)
+	}
+
+	keySetter, err := d.scalarSetter(mapType.Key())
+	if err != nil {
+		return false, newErr(unsupportedTypeError(mapType))
+	}
+
+	result := reflect.MakeMapWithSize(mapType, len(keys))
+	for _, key := range keys {
+		keyValue := reflect.New(mapType.Key()).Elem()
+		if err := keySetter.Set(key, keyValue); err != nil {
+			return false, newErr(elementError{key, err})
+		}
+
+		value := reflect.New(mapType.Elem()).Elem()
+		elemScope.fieldPathPrefix = fmt.Sprintf("%s[%s].", fieldPath, key)
+		elemScope.envVarPrefix = d.scopePrefix(prefix+key, elemScope)
+		if err := d.loadEnvVarsIntoStruct(value, elemScope); err != nil {
+			return false, err
+		}
+
+		result.SetMapIndex(keyValue, value)
+	}
+
+	field.Set(result)
+	return true, nil
+}
+
+// structMapKeys returns the sorted, distinct keys of the variables which begin with prefix and either end with the
+// prefix separator followed by one of suffixes, or continue with the prefix separator followed by one of mapNames and
+// another separator, being that of the scope s. The shortest such key of each variable is used.
+func (d *decodeState) structMapKeys(prefix string, suffixes, mapNames []string, s scope) []string {
+	seen := make(map[string]bool)
+	sep := d.opts.prefixSeparator
+	if s.separator != "" {
+		sep = s.separator
+	}
+	for _, name := range d.enumerableNames() {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		var (
+			rest = name[len(prefix):]
+			key  = rest
+		)
+
+		for _, suffix := range suffixes {
+			if k := strings.TrimSuffix(rest, sep+suffix); k != rest && len(k) < len(key) {
+				key = k
+			}
+		}
+
+		for _, mapName := range mapNames {
+			if i := strings.Index(rest, sep+mapName+sep); i >= 0 && i < len(key) {
+				key = rest[:i]
+			}
+		}
+
+		if key != rest && key != "" {
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldTypeByPath returns the type of the field of the struct type t at path, as reported by describeStruct
+// (e.g. Auth.Servers), following the pointers of embedded structs.
+func fieldTypeByPath(t reflect.Type, path string) reflect.Type {
+	for _, name := range strings.Split(path, ".") {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		field, _ := t.FieldByName(name)
+		t = field.Type
+	}
+	return t
+}
+
+// enumerableNames returns the names of the variables in the source and fallbacks which are able to be listed,
+// which are those of MapSource sources.
+func (d *decodeState) enumerableNames() []string {
+	var names []string
+	for _, src := range append([]Source{d.source}, d.fallbacks...) {
+		if vars, ok := src.(MapSource); ok {
+			for name := range vars {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
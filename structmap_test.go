@@ -0,0 +1,141 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalStructMap(t *testing.T) {
+	type serverConfig struct {
+		Host    string
+		Port    int `env:",default=80"`
+		TLSCert string
+		Cert    string
+	}
+
+	var out struct {
+		Servers map[string]serverConfig
+		Ports   map[int]struct{ Name string }
+		Empty   map[string]serverConfig
+	}
+
+	env := []string{
+		"SERVERS_WEB_HOST=web.internal",
+		"SERVERS_WEB_PORT=8080",
+		"SERVERS_DB_HOST=db.internal",
+		"SERVERS_EU_WEST_HOST=eu.internal",
+		"SERVERS_EDGE_TLS_CERT=edge.pem",
+		"SERVERS_EDGE_CERT=plain.pem",
+		"PORTS_443_NAME=https",
+	}
+
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := map[string]serverConfig{
+		"WEB":     {Host: "web.internal", Port: 8080},
+		"DB":      {Host: "db.internal", Port: 80},
+		"EU_WEST": {Host: "eu.internal", Port: 80},
+		"EDGE":    {Port: 80, TLSCert: "edge.pem", Cert: "plain.pem"},
+	}
+
+	if !reflect.DeepEqual(out.Servers, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, out.Servers)
+	}
+
+	if out.Ports[443].Name != "https" {
+		t.Fatalf("Expected int keys to be parsed, got %+v", out.Ports)
+	}
+
+	if out.Empty != nil {
+		t.Fatalf("Expected a map without variables to be left untouched, got %+v", out.Empty)
+	}
+}
+
+func TestUnmarshalStructMapErrors(t *testing.T) {
+	var out struct {
+		Servers map[string]struct{ Port int }
+		Ports   map[int]struct{ Name string }
+	}
+
+	tt := []struct {
+		env   []string
+		field string
+	}{
+		{[]string{"SERVERS_WEB_PORT=http"}, "Servers[WEB].Port"},
+		{[]string{"PORTS_HTTPS_NAME=https"}, "Ports[HTTPS]"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.field, func(t *testing.T) {
+			var fieldErr FieldParseError
+			err := Unmarshal(tc.env, &out)
+			if !errors.As(err, &fieldErr) || fieldErr.Field() != tc.field {
+				t.Fatalf("Expected a FieldParseError for %s, got %v", tc.field, err)
+			}
+		})
+	}
+
+	var required struct {
+		Servers map[string]struct{ Port int } `env:",required"`
+	}
+
+	if err := Unmarshal(nil, &required); err == nil {
+		t.Fatal("Expected an error for a required map without variables")
+	}
+}
+
+type structMapNode struct {
+	Name     string
+	Children map[string]structMapNode
+}
+
+func TestUnmarshalNestedStructMap(t *testing.T) {
+	var out struct {
+		Root structMapNode
+	}
+
+	env := []string{
+		"ROOT_NAME=root",
+		"ROOT_CHILDREN_A_NAME=a",
+		"ROOT_CHILDREN_A_CHILDREN_B_NAME=b",
+		"ROOT_CHILDREN_C_CHILDREN_D_NAME=d",
+	}
+
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := structMapNode{Name: "root", Children: map[string]structMapNode{
+		"A": {Name: "a", Children: map[string]structMapNode{"B": {Name: "b"}}},
+		"C": {Children: map[string]structMapNode{"D": {Name: "d"}}},
+	}}
+
+	if !reflect.DeepEqual(out.Root, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, out.Root)
+	}
+}
+
+func TestUnmarshalStructMapChildSeparator(t *testing.T) {
+	var out struct {
+		DB struct {
+			Servers map[string]struct{ Host string }
+		} `env:"DB,childsep=."`
+	}
+
+	env := []string{"DB.SERVERS.WEB.HOST=web.internal", "DB.SERVERS_API_HOST=api.internal"}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(out.DB.Servers) != 1 || out.DB.Servers["WEB"].Host != "web.internal" {
+		t.Fatalf("Expected only names joined with the child separator to be used, got %+v", out.DB.Servers)
+	}
+
+	vars, err := Marshal(out)
+	if expected := []string{"DB.SERVERS.WEB.HOST=web.internal"}; err != nil || !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("Expected %q, got %q, %v", expected, vars, err)
+	}
+}
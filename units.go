@@ -0,0 +1,161 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// unitPercent is the value of the `unit` tag option for percentages, e.g. 80%.
+	unitPercent = "percent"
+	// unitBytes is the value of the `unit` tag option for byte sizes, e.g. 512MB. It is also set by the `bytes` option.
+	unitBytes = "bytes"
+)
+
+// validateUnit returns an error if unit is not a supported value of the `unit` tag option.
+func validateUnit(unit string) error {
+	switch unit {
+	case "", unitPercent, unitBytes:
+		return nil
+	default:
+		return fmt.Errorf("unsupported unit %q", unit)
+	}
+}
+
+// unitSetter wraps the setter of a numeric field, such that the unit and suffix specified in its tag are stripped
+// from values before they are parsed. The numeric separators provided via WithNumericSeparators are stripped from
+// byte sizes before their number is split from their unit.
+func unitSetter(next fieldSetter, tag fieldTag, separators string) fieldSetter {
+	if tag.Suffix != "" {
+		next = transformingSetter{next, func(v string) string {
+			return strings.TrimSuffix(v, tag.Suffix)
+		}}
+	}
+
+	switch tag.Unit {
+	case unitPercent:
+		next = percentSetter{next}
+	case unitBytes:
+		next = byteSizeSetter{next, separators}
+	}
+
+	return next
+}
+
+// percentSetter parses values optionally suffixed with a percent sign. Float fields are set to the fraction the
+// percentage represents (e.g. 50% is 0.5), while integer fields are set to the number of percent (e.g. 50% is 50).
+// Values without a percent sign are set as is.
+type percentSetter struct {
+	next fieldSetter
+}
+
+func (p percentSetter) Set(v string, field reflect.Value) error {
+	trimmed := strings.TrimSuffix(v, "%")
+	if err := p.next.Set(trimmed, field); err != nil {
+		return err
+	}
+
+	for field.Kind() == reflect.Pointer {
+		field = field.Elem()
+	}
+
+	if trimmed != v && (field.Kind() == reflect.Float32 || field.Kind() == reflect.Float64) {
+		field.SetFloat(field.Float() / 100)
+	}
+
+	return nil
+}
+
+// byteSizeMultipliers holds the number of bytes in each supported unit, keyed by the lower case unit.
+var byteSizeMultipliers = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// byteSizeSetter parses human-friendly sizes (e.g. 512KB or 2GiB) into a number of bytes, which is set by next.
+type byteSizeSetter struct {
+	next       fieldSetter
+	separators string
+}
+
+func (b byteSizeSetter) Set(v string, field reflect.Value) error {
+	size, err := parseByteSize(stripSeparators(v, b.separators))
+	if err != nil {
+		return err
+	}
+	return b.next.Set(strconv.FormatUint(size, 10), field)
+}
+
+// parseByteSize parses a size formed of a non-negative number followed by an optional unit, which is one of the
+// decimal units B, KB, MB, GB, TB and PB, or the binary units KiB, MiB, GiB, TiB and PiB. Units are case-insensitive.
+func parseByteSize(v string) (uint64, error) {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "-") {
+		return 0, fmt.Errorf("invalid byte size %q: must not be negative", v)
+	}
+
+	unitStart := strings.IndexFunc(v, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+
+	if unitStart == -1 {
+		unitStart = len(v)
+	}
+
+	number, unit := v[:unitStart], strings.TrimSpace(v[unitStart:])
+	multiplier, ok := byteSizeMultipliers[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", v, unit)
+	}
+
+	if number == "" {
+		return 0, fmt.Errorf("invalid byte size %q", v)
+	}
+
+	if !strings.Contains(number, ".") {
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid byte size %q: %w", v, err)
+		}
+
+		hi, size := bits.Mul64(n, multiplier)
+		if hi != 0 {
+			return 0, fmt.Errorf("invalid byte size %q: %w", v, errByteSizeOverflow)
+		}
+		return size, nil
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", v, err)
+	}
+
+	size := n * float64(multiplier)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size %q: %w", v, errByteSizeOverflow)
+	}
+
+	if size != math.Trunc(size) {
+		return 0, fmt.Errorf("invalid byte size %q: not a whole number of bytes", v)
+	}
+
+	return uint64(size), nil
+}
+
+var errByteSizeOverflow = errors.New("value out of range")
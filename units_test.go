@@ -0,0 +1,114 @@
+package env
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnmarshalUnits(t *testing.T) {
+	var out struct {
+		CPU       float64  `env:",unit=percent max=1"`
+		Threshold *float32 `env:",unit=percent"`
+		Usage     int      `env:",unit=percent"`
+		Ratio     float64  `env:",unit=percent"`
+		TimeoutMS int      `env:"TIMEOUT_MS,suffix=ms"`
+	}
+
+	env := []string{"CPU=50%", "THRESHOLD=12.5%", "USAGE=80%", "RATIO=0.25", "TIMEOUT_MS=250ms"}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.CPU != 0.5 || *out.Threshold != 0.125 || out.Usage != 80 || out.Ratio != 0.25 || out.TimeoutMS != 250 {
+		t.Fatalf("Expected units to be interpreted, got %+v", out)
+	}
+
+	tt := []struct {
+		name string
+		env  string
+	}{
+		{"exceeds max after conversion", "CPU=150%"},
+		{"not a number", "USAGE=eighty%"},
+		{"unexpected suffix", "TIMEOUT_MS=250s"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal([]string{tc.env}, &out); err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+
+	var unknown struct {
+		Size int `env:",unit=parsecs"`
+	}
+
+	if err := Unmarshal(nil, &unknown); err == nil {
+		t.Fatal("Expected an error for an unsupported unit")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tt := []struct {
+		value    string
+		expected uint64
+		err      string
+	}{
+		{"1024", 1024, ""},
+		{"512B", 512, ""},
+		{"512KB", 512000, ""},
+		{"512 kb", 512000, ""},
+		{"2GiB", 2 << 30, ""},
+		{"1.5MB", 1500000, ""},
+		{"0.5KiB", 512, ""},
+		{"16PiB", 16 << 50, ""},
+		{"1.5B", 0, `invalid byte size "1.5B": not a whole number of bytes`},
+		{"-5MB", 0, `invalid byte size "-5MB": must not be negative`},
+		{"5XB", 0, `invalid byte size "5XB": unknown unit "XB"`},
+		{"MB", 0, `invalid byte size "MB"`},
+		{"20000PB", 0, `invalid byte size "20000PB": value out of range`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := parseByteSize(tc.value)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("Expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil || actual != tc.expected {
+				t.Fatalf("Expected %d, got %d (%v)", tc.expected, actual, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalByteSizes(t *testing.T) {
+	var out struct {
+		CacheSize  int64  `env:",bytes"`
+		BufferSize *int   `env:",unit=bytes"`
+		Small      uint16 `env:",bytes"`
+	}
+
+	if err := Unmarshal([]string{"CACHE_SIZE=2GiB", "BUFFER_SIZE=64KB"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.CacheSize != 2<<30 || *out.BufferSize != 64000 {
+		t.Fatalf("Expected sizes to be parsed, got %+v", out)
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"SMALL=1MB"}, &out); !errors.As(err, &fieldErr) || fieldErr.Field() != "Small" {
+		t.Fatalf("Expected a FieldParseError for a size overflowing the field, got %v", err)
+	}
+
+	err := Unmarshal([]string{"CACHE_SIZE=1_000KB", "BUFFER_SIZE=1_024"}, &out, WithNumericSeparators("_"))
+	if err != nil || out.CacheSize != 1000000 || *out.BufferSize != 1024 {
+		t.Fatalf("Expected numeric separators to be stripped from sizes, got %v, %+v", err, out)
+	}
+}
@@ -1,52 +1,125 @@
 package env
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
+	"unsafe"
 )
 
 type Unmarshaler interface {
 	UnmarshalEnv(v string) error
 }
 
+// ContextUnmarshaler is like [Unmarshaler], but receives the context passed to [UnmarshalContext], allowing
+// implementations which perform I/O (e.g. resolving a secret reference) to respect deadlines and cancellation.
+// When a type implements both interfaces, ContextUnmarshaler is preferred.
+type ContextUnmarshaler interface {
+	UnmarshalEnvContext(ctx context.Context, v string) error
+}
+
 // Unmarshal accepts a list of environment variables, typically sourced from [os.Environ], and attempts
-// to unmarshal the provided variables into out, which must be a non-nil pointer to a struct.
-// Assuming out is a valid pointer to a struct, the error returned by [Unmarshal] will always implement the [FieldParseError] interface.
+// to unmarshal the provided variables into out, which must be a non-nil pointer to a struct, or to a collection
+// (see [UnmarshalPrefix]).
+// Assuming out is a valid pointer to a struct, the error returned by [Unmarshal] will implement the [FieldParseError]
+// interface, except for errors wrapping [ErrDuplicateEnvVars] (see [WithRejectDuplicates]) or [ErrUnknownEnvVars]
+// (returned by strict variants such as [UnmarshalPrefixStrict]), which do not concern a single field.
+// Otherwise, the error returned wraps [ErrInvalidTarget].
 //
 // Unmarshal will attempt set values on a given struct field, according to the following ruleset:
 //
 //  1. Determine the correct environment variable for the struct field:
 //
+//     - Was a name provided for the field's path via [WithFieldOverrides]? If yes, use this name.
+//
 //     - Does the field have a name in the `env:""` tag? If yes, use this name.
 //
-//     - Construct the field name by inserting an underscore between any two letters where a lower case letter,
+//     - Does the field have a name in the tag provided via [WithFallbackTag] (e.g. `json:"signingKey,omitempty"`)?
+//     If yes, construct the name from it as below, in place of the field's name, prefixed as usual.
+//
+//     - If the field is nested within a struct tagged with `env:",raw"`, use the field's Go name verbatim, prefixed
+//     as usual. (e.g. ApiKey -> THIRD_PARTY_ApiKey)
+//
+//     - Otherwise, construct the field name by inserting an underscore between any two letters where a lower case letter,
 //     is immediately followed by an upper case letter. (e.g. fooBar -> FOO_BAR)
 //
 //     - OR, insert an underscore prior to any upper case letter that is
 //     immediately followed by a lower case letter. (e.g. JSONString -> JSON_STRING)
 //
-//  2. Check if an environment variable exists with by the name determined in step 1.
+//     - When [WithLowercaseNames] is provided, the constructed name is lower case instead. (e.g. fooBar -> foo_bar)
+//
+//  2. If the field is tagged with the `enableIf` option (e.g. `env:"METRICS,enableIf=METRICS_ENABLED"`), skip the
+//     field entirely unless the named environment variable is set to a true value, parsed just like a bool field.
+//     Skipping a nested struct skips all of its fields, including required ones, so optional sections need only be
+//     configured when they are enabled.
+//
+//     Then, check if an environment variable exists with by the name determined in step 1.
 //
 //     - If yes, use this value in step 3.
 //
-//     - Otherwise, check if a default value was specified in the `env:",default="` tag.
+//     - Otherwise, check each alias specified in the `env:",alias="` tag, in order, using the value of the first
+//     alias that exists in step 3. (e.g. `env:"NEW_NAME,alias=OLD_NAME alias=LEGACY_NAME"`)
+//
+//     - Otherwise, check each map provided via [WithFallback], in order, for the name or any of its aliases, using
+//     the first value found in step 3.
+//
+//     - Otherwise, if the `defaultFrom` option names another environment variable (e.g.
+//     `env:"PRIMARY,defaultFrom=SECONDARY"`) which is set, or which populated a previous field, use its value in
+//     step 3. Unlike an alias, the other variable is not deprecated, but is an intentional source of the default.
+//
+//     - Otherwise, check if a default value was specified in the `env:",default="` tag. When [WithKeepPresetValues]
+//     is provided and the field already holds a non-zero value, the default, defaultFrom and any default function
+//     are ignored.
 //
 //     -- If yes, use this value in step 3.
 //
-//     -- Otherwise, stop processing the field. (i.e. do not continue to step 3.)
+//     -- Otherwise, check if a default function was specified in the `env:",defaultFunc="` tag, and if so, use the
+//     value it returns in step 3. See [RegisterDefaultFunc].
+//
+//     -- Otherwise, if the field is tagged with the `required` option, return an error. An explanation may be
+//     included in the error via the `msg` option, e.g. `env:",required msg=API key from the dashboard"`. Every word
+//     following msg is part of the explanation, keywords included, so other options must precede it. See also
+//     [WithRequireAll].
+//
+//     -- Otherwise, if the field is tagged with the `requiredIf` option (e.g. `env:"CERT_FILE,requiredIf=TLS_ENABLED=true"`)
+//     and the named environment variable has the given value, return an error.
+//
+//     -- Otherwise, stop processing the field. (i.e. do not continue to step 3.) The field is left untouched, so any
+//     value set on it prior to calling Unmarshal is preserved.
+//
+//     If the field is tagged with the `fromFile` option (e.g. `env:"TLS_KEY,fromFile"`), the value is the path of
+//     a file, and the contents of that file are used in step 3 instead.
 //
-//  3. Check if the field type implements the Unmarshaler interface.
+//  3. Check if the field is tagged with the `json` option (e.g. `env:"CONFIG,json"`).
 //
-//     - If yes, invoke the [Unmarshaler.UnmarshalEnv], returning the error if non-nil.
+//     - If yes, decode the environment variable value into the field using [json.Unmarshal].
 //
-//     - Otherwise, check if the field is a struct.
+//     - Otherwise, check if the field type implements the Unmarshaler interface.
 //
-//     -- If yes, parse the struct fields, starting back at step 1.
+//     -- If yes, invoke the [Unmarshaler.UnmarshalEnv], returning the error if non-nil. If the field type implements
+//     [ContextUnmarshaler], [ContextUnmarshaler.UnmarshalEnvContext] is invoked instead.
 //
-//     -- Otherwise, attempt to parse the environment variable value into the correct type, and set it on the field.
+//     -- Otherwise, check if the field is a struct.
+//
+//     --- If yes, parse the struct fields, starting back at step 1. The fields of an anonymous embedded struct are
+//     promoted, so their names are not prefixed with the embedded struct's name unless one is set via the `env` tag.
+//     Just like encoding/json, this applies whether or not the embedded struct's type is exported, and to embedded
+//     pointers to structs, which are only allocated when one of their fields is populated.
+//     Likewise, the fields of a struct tagged with `env:",flatten"` are never prefixed with the struct's name.
+//     The separator joining the struct's name to the names of its fields, and those of any structs nested within
+//     it, may be set via the `childsep` option (e.g. `env:"DB,childsep=."` yields DB.HOST), overriding
+//     [WithPrefixSeparator] for that subtree only.
+//     A struct without exported fields, such as struct{}, is left untouched, though tagging it as `required` is an
+//     error, since nothing could satisfy it.
+//
+//     --- Otherwise, attempt to parse the environment variable value into the correct type, and set it on the field.
 //
 // # Supported field types
 //
@@ -68,37 +141,558 @@ type Unmarshaler interface {
 //   - float64
 //   - []byte
 //   - []rune
+//   - json.RawMessage, which always holds the value verbatim, without it being validated or decoded
+//   - big.Int
+//   - big.Float
+//   - net.IP
+//   - net.IPNet, formatted as a CIDR (e.g. 10.0.0.0/8)
+//   - net.HardwareAddr, formatted as accepted by [net.ParseMAC] (e.g. 01:23:45:67:89:ab)
+//   - url.URL, formatted according to [url.Parse]
+//   - time.Duration, formatted according to [time.ParseDuration]
+//   - time.Time, formatted according to [time.RFC3339], or as an integer Unix timestamp when tagged with one of the
+//     `unix`, `unixmilli` or `unixnano` options (e.g. `env:"TS,unix"`)
+//   - the database/sql Null types, such as sql.NullString and sql.NullInt64, which are marked Valid only when a
+//     value is set
+//   - slices and arrays of any of the above scalar types, or of Unmarshaler implementations, e.g. []int or [4]float64
+//   - maps with keys of any of the above scalar types, and values of any of the above scalar types or of Unmarshaler
+//     implementations, e.g. map[string]int
+//   - maps with struct values, e.g. map[string]ServerConfig, populated from the variables named by the map's prefix,
+//     followed by the key, followed by the names of the struct's fields (e.g. SERVERS_WEB_HOST and SERVERS_DB_HOST
+//     populate the Host field at the keys WEB and DB). Keys are discovered by listing the environment, so only
+//     variables provided via a [MapSource], or a slice of key=value pairs, are considered.
+//   - interfaces with factories registered via [RegisterFactory]
+//   - enums registered via [RegisterEnum]
+//   - [Lazy] values, which are resolved when first read, rather than by Unmarshal
+//   - structs with exactly two exported fields of the same scalar type, when tagged with the `range` option
+//     (e.g. `env:"PORTS,range"`), parsed from a value of the form min-max (e.g. 8000-8100) into the first and second
+//     fields respectively. Numeric and net.IP ranges whose minimum is greater than their maximum are an error.
+//   - structs tagged with the `kv` option (e.g. `env:"DSN,kv"`), populated from a single value of key=value pairs
+//     separated by commas or white space, or by the delimiter when one is specified (e.g. DSN=host=localhost port=5432).
+//     Keys are matched case-insensitively to the names the struct's fields would have without any prefix, and their
+//     values are parsed just as if they were environment variables. Keys matching no field are ignored, unless the
+//     `strict` option is also provided (e.g. `env:"DSN,kv strict"`), in which case they are an error.
+//
+// Slices tagged with the `indexed` option (e.g. `env:"TAG,indexed"`) are alternatively populated from variables
+// named by the field's name followed by contiguous indices starting at 0 (e.g. TAG_0=a and TAG_1=b yield [a b]) when
+// the field's own variable is not set. The first index which is not set ends the slice, so TAG_0 and TAG_2 yield only
+// the element of TAG_0, and indices not starting at 0 yield nothing. When no indexed variable is set, the field is
+// resolved as usual.
+//
+// A single variable encoding several values, such as HOST_PORT=localhost:8080, may populate several fields via a
+// fanout declared on a blank field; see [RegisterFanout].
+//
+// Slice, array and map values are split on commas. When tagged with the `csv` option (e.g. `env:"HOSTS,csv"`),
+// values are split according to the quoting rules of [encoding/csv] instead, such that "a,b",c yields the
+// elements a,b and c. When tagged with the `ossep` option (e.g. `env:"SEARCH_PATHS,ossep"`), values are instead split
+// on [os.PathListSeparator], i.e. ':' on Unix and ';' on Windows, such that PATH-style values are portable across
+// platforms. Any other delimiter may be specified via the `delim` option (e.g. `env:"HOSTS,delim=;"`), taking
+// precedence over [WithDelimiter], which sets the delimiter of every field. A delimiter may be combined with the
+// `csv` option to quote elements containing it, provided it is a single character. An array value must contain
+// exactly as many elements as the array's length. Each element of a map value must be a key=value pair
+// (e.g. LABELS=team=core,tier=1).
+// When an element fails to parse, its index or key is included in the [FieldParseError.Field] (e.g. Hosts[3]).
+//
+// Pointers to any of the above types, such as *time.Duration or *url.URL, are allocated only when a value is found
+// for the field, and are otherwise left untouched, i.e. nil unless set prior to calling Unmarshal.
+//
+// Note: pointers to [Unmarshaler] implementations are supported, as are interface fields already holding an
+// implementation (or a value whose pointer is one) prior to calling Unmarshal. A nil interface field is left
+// untouched when no value is found for it.
+//
+// # Validation
+//
+// String fields may be constrained to an exact or maximum number of runes via the `len` and `maxlen` options,
+// e.g. `env:"COUNTRY,len=2"` or `env:",maxlen=32"`.
+//
+// Integer and float fields may be constrained to an inclusive range via the `min` and `max` options,
+// e.g. `env:"WORKERS,min=1 max=128"`. Omitting either bound leaves that side unconstrained.
+//
+// Values may be restricted to a fixed set via the `oneof` option, e.g. `env:"LEVEL,oneof=debug info warn error"`.
+// The comparison is case-sensitive, unless the `ignorecase` option is also provided. Every word following oneof is
+// an allowed value, so other options must precede it (e.g. `env:"LEVEL,ignorecase oneof=debug info"`), and an option
+// keyword following it is an error. Values may instead be separated by |, which allows values that are themselves
+// keywords, e.g. `env:"FORMAT,oneof=text|json"`.
+//
+// Alternative configuration modes may be made mutually exclusive via the `xor` option, naming the environment
+// variable of the alternative, e.g. `env:"TLS_CERT,xor=TLS_CERT_FILE"`. Once every field has been resolved,
+// exactly one of the two must have a value, including from a default; otherwise, both having a value or neither
+// having one is an error.
+//
+// A value violating a constraint results in a [FieldParseError].
+//
+// # Units
 //
-// Note: pointers to [Unmarshaler] implementations are supported.
-func Unmarshal(env []string, out any) error {
-	return UnmarshalPrefix(env, out, "")
+// A literal suffix may be stripped from the values of integer and float fields before they are parsed via the
+// `suffix` option, e.g. `env:"TIMEOUT_MS,suffix=ms"` accepts both 250ms and 250.
+//
+// Percentages are accepted via the `unit=percent` option, e.g. `env:"CPU,unit=percent"`, where the percent sign
+// is optional. Float fields are set to the fraction a percentage represents (50% is 0.5), while integer fields are
+// set to the number of percent (50% is 50). Values without a percent sign are set as is.
+//
+// Byte sizes are accepted via the `bytes` option, or equivalently `unit=bytes`, e.g. `env:"CACHE_SIZE,bytes"`, such
+// that 512KB is 512000 and 2GiB is 2147483648. The decimal units B, KB, MB, GB, TB and PB, and the binary units
+// KiB, MiB, GiB, TiB and PiB are supported, case-insensitively. A number without a unit is a number of bytes.
+//
+// # String transforms
+//
+// String values may be normalized before they are set (and validated) via the `trim`, `lower` and `upper` options,
+// e.g. `env:"ENV,trim lower"`. When combined, whitespace is trimmed first, followed by the case conversion.
+//
+// # Secret fields
+//
+// Fields tagged with the `secret` option (e.g. `env:"DB_PASS,secret"`) never have their raw value included in
+// the message of a [FieldParseError]; see [FieldParseError.Secret].
+//
+// # Options
+//
+// The behavior of Unmarshal may be customized by providing any number of [Option] values, such as [WithPrefix].
+func Unmarshal(env []string, out any, opts ...Option) error {
+	return NewDecoder(opts...).Decode(env, out)
+}
+
+// UnmarshalPopulated is just like [Unmarshal], but additionally returns the paths (e.g. "Auth.SigningKey") of the
+// fields which were populated from either an environment variable or a default, in the order they were set.
+// This allows callers to distinguish fields which were explicitly set to their zero value from those which
+// were left untouched.
+func UnmarshalPopulated(env []string, out any, opts ...Option) ([]string, error) {
+	d := newEnvDecodeState(context.Background(), env, newOptions(opts))
+	err := d.unmarshal(out)
+	return d.populated, err
+}
+
+// UnmarshalContext is just like [Unmarshal], but passes ctx to the [ContextUnmarshaler] implementations of fields.
+func UnmarshalContext(ctx context.Context, env []string, out any, opts ...Option) error {
+	return NewDecoder(opts...).DecodeContext(ctx, env, out)
+}
+
+// UnmarshalArgs is just like [Unmarshal], but populates out from command-line style arguments, such as os.Args[1:],
+// allowing configuration to be overridden on the command line using the same struct and tags, e.g.
+// `./server PORT=8080 LOG_LEVEL=debug`. Each argument of the form KEY=VALUE is treated exactly as an environment
+// variable, with the value being everything after the first =. Arguments which are not of that form, such as the
+// program name or arguments beginning with =, are ignored, as are flags beginning with -, such as --verbose or
+// --level=debug.
+//
+// Fields for which no argument is found are resolved just as by [Unmarshal], such that defaults apply and required
+// fields result in an error. To overlay arguments on a struct already populated from the environment, pass
+// [WithKeepPresetValues] such that the values fields already hold are neither replaced by defaults, nor reported as
+// missing when required.
+func UnmarshalArgs(args []string, out any, opts ...Option) error {
+	env := make([]string, 0, len(args))
+	for _, arg := range args {
+		if i := strings.IndexByte(arg, '='); i > 0 && arg[0] != '-' {
+			env = append(env, arg)
+		}
+	}
+
+	return Unmarshal(env, out, opts...)
 }
 
 // UnmarshalPrefix is just like [Unmarshal], but allows the caller to provide a prefix, which will be prepended to
 // field environment variable names (excepting those that are explicitly set via the `env` tag.
-func UnmarshalPrefix(env []string, out any, prefix string) error {
+// The prefix is joined to names with exactly one underscore, whether or not it already ends with any;
+// see [WithPrefixSeparator].
+// The prefix takes precedence over any [WithPrefix] option.
+//
+// A struct may also declare a prefix for its own fields via the `prefix` option on a blank field, e.g.
+// _ struct{} `env:",prefix=APP"`, keeping the prefix coupled to the type. When both are provided, the prefix provided
+// by the caller comes first, such that the prefix ACME and the declared prefix APP yield names such as ACME_APP_PORT.
+// The prefix declared by a nested struct is likewise appended to the prefix its fields would otherwise have.
+//
+// Rather than a struct, out may point to a collection. A map holds every variable beginning with the prefix,
+// keyed by the remainder of its name (e.g. the prefix LABELS and the variable LABELS_TEAM=core yield the entry
+// TEAM=core), with values parsed according to the map's value type. A slice or array is parsed from the single
+// variable named by the prefix (e.g. the prefix HOSTS and the variable HOSTS=a,b yield [a b]).
+func UnmarshalPrefix(env []string, out any, prefix string, opts ...Option) error {
+	return Unmarshal(env, out, append(opts[:len(opts):len(opts)], WithPrefix(prefix))...)
+}
+
+// UnmarshalPrefixStrict is just like [UnmarshalPrefix], but additionally returns an error wrapping
+// [ErrUnknownEnvVars] which lists every environment variable beginning with the prefix that does not correspond to
+// any field, helping to catch typos within the namespace owned by a service. Variables without the prefix are ignored.
+func UnmarshalPrefixStrict(env []string, out any, prefix string, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], WithPrefix(prefix))
+	d := newEnvDecodeState(context.Background(), env, newOptions(opts))
+	if err := d.unmarshal(out); err != nil {
+		return err
+	}
+
+	return d.checkUnknownEnvVars(d.source.(MapSource), prefix)
+}
+
+// ValidateEnv runs the same resolution and parsing as [Unmarshal], but against a copy of the struct pointed to by
+// out, including the values pointed to by its fields, such that out is left untouched. Rather than stopping at the
+// first invalid field, every invalid field is reported in the returned error, which wraps an [AggregateError].
+// Collections are supported just as by [UnmarshalPrefix], being validated against a zero value of their type.
+func ValidateEnv(env []string, out any, opts ...Option) error {
+	d := newEnvDecodeState(context.Background(), env, newOptions(opts))
+	d.collectErrors = true
+	if value, ok := collectionTarget(out); ok {
+		return d.unmarshal(reflect.New(value.Type()).Interface())
+	}
+
+	value, err := targetValue(out)
+	if err != nil {
+		return err
+	}
+
+	scratch := reflect.New(value.Type())
+	scratch.Elem().Set(value)
+	d.copyPointers(scratch.Elem(), scope{}, make(map[uintptr]reflect.Value))
+	return d.unmarshal(scratch.Interface())
+}
+
+// copyPointers replaces the pointers held by the fields of the struct v, and of the structs nested within it, with
+// pointers to copies of the values they point to, such that populating v leaves the values pointed to by the original
+// untouched. Only the pointers populating may write through are copied, so ignored fields, fields of unsupported
+// types and structs nested beyond the maximum depth are skipped, and the values pointed to are copied shallowly,
+// except for embedded structs. These are tracked in visited by their original address, such that cycles are copied
+// as cycles.
+func (d *decodeState) copyPointers(v reflect.Value, s scope, visited map[uintptr]reflect.Value) {
+	if s.depth > d.opts.maxDepth {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field, ok := d.structField(v, i)
+		if !ok {
+			continue
+		}
+
+		fieldType := v.Type().Field(i)
+		fTag, _ := parseFieldTag(fieldType.Tag.Get("env"))
+		fieldPath := s.fieldPathPrefix + fieldType.Name
+		if fTag.Name == "-" || d.opts.ignoreFields[fieldPath] {
+			continue
+		}
+
+		nested := scope{fieldPathPrefix: fieldPath + ".", depth: s.depth + 1}
+		switch {
+		case d.isNestedStruct(field.Type(), fTag):
+			d.copyPointers(field, nested, visited)
+		case fieldType.Anonymous && field.Kind() == reflect.Pointer && d.isNestedStruct(field.Type().Elem(), fTag):
+			if field.IsNil() {
+				continue
+			}
+
+			if copied, ok := visited[field.Pointer()]; ok {
+				field.Set(copied)
+				continue
+			}
+
+			copied := copyPointerChain(field)
+			visited[field.Pointer()] = copied
+			field.Set(copied)
+			d.copyPointers(copied.Elem(), nested, visited)
+		case field.Kind() == reflect.Pointer:
+			_, err := d.validateFieldAndReturnSetter(field, fTag)
+			if errors.Is(err, ErrUnsupportedType) && d.opts.unsupportedHandler == nil {
+				continue
+			}
+			field.Set(copyPointerChain(field))
+		case field.Kind() == reflect.Interface && !field.IsNil() && field.Elem().Kind() == reflect.Pointer:
+			field.Set(copyPointerChain(field.Elem()))
+		}
+	}
+}
+
+// copyPointerChain returns a copy of the pointer p, and of any pointers it points to, pointing to a shallow copy of
+// the value at the end of the chain.
+func copyPointerChain(p reflect.Value) reflect.Value {
+	if p.IsNil() {
+		return p
+	}
+
+	copied := reflect.New(p.Type().Elem())
+	if p.Elem().Kind() == reflect.Pointer {
+		copied.Elem().Set(copyPointerChain(p.Elem()))
+	} else {
+		copied.Elem().Set(p.Elem())
+	}
+	return copied
+}
+
+// Load is shorthand for calling [Unmarshal] with the environment of the current process, as returned by [os.Environ].
+func Load(out any, opts ...Option) error {
+	return Unmarshal(os.Environ(), out, opts...)
+}
+
+// decodeState holds the state of a single call to [Unmarshal].
+type decodeState struct {
+	ctx       context.Context
+	opts      options
+	source    Source
+	fallbacks []Source
+	populated []string
+
+	// known holds the names of every environment variable looked up for a field, folded by fold.
+	known map[string]bool
+
+	// resolved holds the values fields were populated with, keyed by environment variable name.
+	// Like the keys of MapSource sources, its keys are folded to upper case when WithCaseInsensitive is provided.
+	resolved map[string]string
+
+	// defaults holds the values of the fields of the struct provided to UnmarshalWithDefaults, keyed by field path.
+	defaults map[string]reflect.Value
+
+	// exclusive holds the fields tagged with the xor option, which are checked once every field is resolved.
+	exclusive []exclusiveField
+
+	// duplicates holds the names, folded by fold, which appear more than once in the environment being unmarshaled.
+	duplicates []string
+
+	// collectErrors causes field errors to be accumulated in errs, rather than aborting on the first one.
+	collectErrors bool
+	errs          []error
+}
+
+func newDecodeState(ctx context.Context, src Source, opts options) *decodeState {
+	d := &decodeState{
+		ctx:      ctx,
+		opts:     opts,
+		known:    make(map[string]bool),
+		resolved: make(map[string]string),
+	}
+
+	d.source = d.foldSource(src)
+	for _, fallback := range opts.fallbacks {
+		d.fallbacks = append(d.fallbacks, d.foldSource(MapSource(fallback)))
+	}
+
+	return d
+}
+
+// fold returns the key under which the environment variable name is stored, which is name itself, or its
+// upper case form when WithCaseInsensitive is provided.
+func (d *decodeState) fold(name string) string {
+	if d.opts.caseInsensitive {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+// foldSource returns src with each of its keys folded by fold when it is a MapSource. The names looked up in any
+// other Source are folded, so such sources must store their keys in upper case when WithCaseInsensitive is provided.
+func (d *decodeState) foldSource(src Source) Source {
+	vars, ok := src.(MapSource)
+	if !ok || !d.opts.caseInsensitive {
+		return src
+	}
+
+	folded := make(MapSource, len(vars))
+	for name, value := range vars {
+		folded[d.fold(name)] = value
+	}
+	return folded
+}
+
+func (d *decodeState) unmarshal(out any) error {
+	if d.opts.rejectDuplicates && len(d.duplicates) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateEnvVars, strings.Join(d.duplicates, ", "))
+	}
+
+	if value, ok := collectionTarget(out); ok {
+		if err := d.loadCollection(value); err != nil {
+			return fmt.Errorf("failed to unmarshal environment variables into %T: %w", out, err)
+		}
+		return nil
+	}
+
+	value, err := targetValue(out)
+	if err != nil {
+		return err
+	}
+
+	if err := d.loadEnvVarsIntoStruct(value, scope{envVarPrefix: d.joinPrefix(d.opts.prefix)}); err != nil {
+		return fmt.Errorf("failed to unmarshal environment variables into struct %T: %w", out, err)
+	}
+
+	if err := d.checkExclusiveFields(); err != nil {
+		return fmt.Errorf("failed to unmarshal environment variables into struct %T: %w", out, err)
+	}
+
+	if len(d.errs) > 0 {
+		return fmt.Errorf("failed to unmarshal environment variables into struct %T: %w", out, AggregateError{d.errs})
+	}
+
+	return nil
+}
+
+// targetValue returns the struct pointed to by out, or an error wrapping ErrInvalidTarget.
+func targetValue(out any) (reflect.Value, error) {
 	if out == nil {
-		return errors.New("env: out must be a non-nil pointer to a struct")
+		return reflect.Value{}, fmt.Errorf("%w: got nil", ErrInvalidTarget)
 	}
 
 	ptr := reflect.ValueOf(out)
 	if ptr.Kind() != reflect.Pointer {
-		return errors.New("out must be a non-nil pointer to a struct")
+		return reflect.Value{}, fmt.Errorf("%w: got %T", ErrInvalidTarget, out)
 	}
 
 	value := ptr.Elem()
+	if _, ok := collectionTarget(out); ok {
+		return reflect.Value{}, fmt.Errorf("%w: got %T, but only structs are supported here", ErrInvalidTarget, out)
+	}
+
 	if value.Kind() != reflect.Struct {
-		return errors.New("out must be a non-nil pointer to a struct")
+		return reflect.Value{}, fmt.Errorf("%w: got %T", ErrInvalidTarget, out)
 	}
 
-	envVars := parseEnv(env)
-	if err := loadEnvVarsIntoStruct(value, envVars, "", prefix); err != nil {
-		return fmt.Errorf("failed to unmarshal environment variables into struct %T: %w", out, err)
+	return value, nil
+}
+
+// expandDefault replaces references to the environment variable names of previously resolved fields
+// (e.g. ${PORT}) with their values.
+func (d *decodeState) expandDefault(v string) (string, error) {
+	var unresolved []string
+	expanded := os.Expand(v, func(name string) string {
+		value, ok := d.resolved[d.fold(name)]
+		if !ok {
+			unresolved = append(unresolved, name)
+		}
+		return value
+	})
+
+	if len(unresolved) > 0 {
+		return "", fmt.Errorf("default value references unresolved fields %s", strings.Join(unresolved, ", "))
+	}
+
+	return expanded, nil
+}
+
+// checkUnknownEnvVars returns an error listing the variables in vars which begin with prefix, but were not looked
+// up for any field.
+func (d *decodeState) checkUnknownEnvVars(vars MapSource, prefix string) error {
+	unknown := d.unknownEnvVars(vars, prefix)
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w with prefix %q: %s", ErrUnknownEnvVars, d.fold(d.joinPrefix(prefix)), strings.Join(unknown, ", "))
+}
+
+// unknownEnvVars returns the sorted names of the variables in vars which begin with prefix, but were not looked up
+// for any field.
+func (d *decodeState) unknownEnvVars(vars MapSource, prefix string) []string {
+	prefix = d.fold(d.joinPrefix(prefix))
+	var unknown []string
+	for name := range vars {
+		if strings.HasPrefix(name, prefix) && !d.known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// exclusiveField is a field tagged with the xor option, such that exactly one of its environment variable and other
+// must be set.
+type exclusiveField struct {
+	field  string
+	envVar string
+	other  string
+}
+
+// checkExclusiveFields returns an error for the first field tagged with the xor option for which either both or
+// neither of the variables were set, or accumulates an error for each such field when collecting errors.
+// A pair of fields tagged with xor referring to one another is only reported once.
+func (d *decodeState) checkExclusiveFields() error {
+	reported := make(map[[2]string]bool)
+	for _, f := range d.exclusive {
+		_, set := d.resolved[d.fold(f.envVar)]
+		_, otherSet := d.resolvedValue(f.other)
+
+		var err error
+		switch {
+		case set && otherSet:
+			err = fmt.Errorf("%s and %s are mutually exclusive", f.envVar, f.other)
+		case !set && !otherSet:
+			err = fmt.Errorf("exactly one of %s and %s must be set", f.envVar, f.other)
+		default:
+			continue
+		}
+
+		pair := [2]string{d.fold(f.envVar), d.fold(f.other)}
+		if reported[[2]string{pair[1], pair[0]}] {
+			continue
+		}
+		reported[pair] = true
+
+		err = newFieldParseError(err, f.field, f.envVar, "")
+		if !d.collectErrors {
+			return err
+		}
+		d.errs = append(d.errs, err)
 	}
 
 	return nil
 }
 
+// resolvedValue returns the value resolved for a previously processed field with the environment variable name,
+// or otherwise the value of the environment variable itself.
+func (d *decodeState) resolvedValue(name string) (string, bool) {
+	if value, ok := d.resolved[d.fold(name)]; ok {
+		return value, true
+	}
+
+	value, _, ok := d.lookup(d.source, name)
+	return value, ok
+}
+
+// joinPrefix returns the prefix followed by exactly one of the configured prefix separator, such that it may be
+// prepended to an environment variable name. Any separators the prefix already ends with are replaced.
+func (d *decodeState) joinPrefix(prefix string) string {
+	return joinPrefixWith(prefix, d.opts.prefixSeparator)
+}
+
+// joinPrefixWith is just like joinPrefix, but joins the prefix with sep rather than the prefix separator.
+func joinPrefixWith(prefix, sep string) string {
+	for sep != "" && strings.HasSuffix(prefix, sep) {
+		prefix = strings.TrimSuffix(prefix, sep)
+	}
+
+	if prefix == "" {
+		return ""
+	}
+	return prefix + sep
+}
+
+// lookup returns the value of the first of names which is found in src, along with the name it was found by.
+// Empty values are treated as not found when WithTreatEmptyAsUnset is provided.
+func (d *decodeState) lookup(src Source, names ...string) (value, name string, ok bool) {
+	return d.lookupEmpty(src, !d.opts.emptyAsUnset, names...)
+}
+
+// lookupEmpty is just like lookup, but treats empty values as not found unless allowEmpty is set.
+func (d *decodeState) lookupEmpty(src Source, allowEmpty bool, names ...string) (value, name string, ok bool) {
+	for _, name := range names {
+		if value, ok := src.Lookup(d.fold(name)); ok && (value != "" || allowEmpty) {
+			return value, name, true
+		}
+	}
+	return "", "", false
+}
+
+// newEnvDecodeState returns a decodeState for the variables in env, formatted as key=value pairs, recording the
+// names which appear more than once for WithRejectDuplicates.
+func newEnvDecodeState(ctx context.Context, env []string, opts options) *decodeState {
+	d := newDecodeState(ctx, MapSource(parseEnv(env)), opts)
+	seen := make(map[string]int, len(env))
+	for _, v := range env {
+		name, _, ok := strings.Cut(v, "=")
+		if !ok {
+			continue
+		}
+
+		name = d.fold(name)
+		if seen[name]++; seen[name] == 2 {
+			d.duplicates = append(d.duplicates, name)
+		}
+	}
+
+	return d
+}
+
 func parseEnv(vars []string) map[string]string {
 	m := make(map[string]string, len(vars))
 	for _, v := range vars {
@@ -111,22 +705,50 @@ func parseEnv(vars []string) map[string]string {
 	return m
 }
 
-func loadEnvVarsIntoStruct(out reflect.Value, envVars map[string]string, fieldPath, envVarPrefix string) error {
+// scope holds the state inherited by the fields of a struct from the fields it is nested within.
+type scope struct {
+	// fieldPathPrefix is prepended to the names of the struct's fields to produce their paths (e.g. "Auth.").
+	fieldPathPrefix string
+	// envVarPrefix is prepended to the environment variable names of the struct's fields (e.g. "AUTH_").
+	envVarPrefix string
+	// rawNames causes the struct's fields to use their Go names verbatim as their environment variable names.
+	rawNames bool
+	// depth is the number of structs the struct is nested within.
+	depth int
+	// fanout holds the values of the struct's fields populated via the fanouts it declares, keyed by field name.
+	fanout map[string]fanoutValue
+	// separator joins the prefix segments of the struct's nested fields in place of the prefix separator, when
+	// specified via the childsep option of the struct or any struct it is nested within.
+	separator string
+}
+
+func (d *decodeState) loadEnvVarsIntoStruct(out reflect.Value, s scope) error {
 	numFields := out.NumField()
 	outType := out.Type()
 	if numFields == 0 {
 		return nil
 	}
 
+	s = d.structScope(outType, s)
+	s, err := d.fanoutScope(outType, s)
+	if err != nil {
+		if !d.collectErrors {
+			return err
+		}
+		d.errs = append(d.errs, err)
+	}
+
 	for i := 0; i < numFields; i++ {
-		field := out.Field(i)
-		fieldType := outType.Field(i)
-		if !fieldType.IsExported() {
+		field, ok := d.structField(out, i)
+		if !ok {
 			continue
 		}
 
-		if err := processField(field, fieldType, envVars, fieldPath, envVarPrefix); err != nil {
-			return err
+		if err := d.processField(field, outType.Field(i), s); err != nil {
+			if !d.collectErrors {
+				return err
+			}
+			d.errs = append(d.errs, err)
 		}
 	}
 
@@ -134,80 +756,650 @@ func loadEnvVarsIntoStruct(out reflect.Value, envVars map[string]string, fieldPa
 }
 
 type fieldTag struct {
-	Name       string
+	Name string
+	// Aliases are checked, in order, when the environment variable for Name is not set.
+	Aliases    []string
 	Default    string
 	HasDefault bool
-	Required   bool
+	// DefaultFunc is the name of the function registered via RegisterDefaultFunc which computes the default.
+	DefaultFunc string
+	// DefaultFrom is the name of an environment variable whose value is the default, taking precedence over Default.
+	DefaultFrom string
+	Required    bool
+	// RequiredMessage explains why a required field matters, and is included in the missing required value error.
+	RequiredMessage string
+	// RequiredIfVar and RequiredIfValue make the field required only when the environment variable RequiredIfVar
+	// has the value RequiredIfValue.
+	RequiredIfVar   string
+	RequiredIfValue string
+	Secret          bool
+	Flatten         bool
+	JSON            bool
+	// AllowEmpty treats an empty value as a value, overriding WithTreatEmptyAsUnset.
+	AllowEmpty bool
+	// Xor is the name of an environment variable which must be set if, and only if, the field's is not.
+	Xor string
+	// Indexed causes a slice to be populated from variables suffixed with contiguous indices, e.g. TAG_0 and TAG_1.
+	Indexed bool
+	// Prefix is the prefix a struct declares for its fields via a blank field.
+	Prefix string
+	// Fanout is the name of the function registered via RegisterFanout which a blank field declares to split its
+	// variable into the values of the fields named by Into.
+	Fanout string
+	Into   []string
+	// ChildSep separates the prefix segments of a nested struct's fields, and those of their descendants, taking
+	// precedence over the prefix separator.
+	ChildSep string
+	// KV causes a struct to be populated from a single value of key=value pairs, which must all match a field
+	// when Strict is set.
+	KV     bool
+	Strict bool
+	// Range causes a struct with two fields to be parsed from a single value of the form min-max.
+	Range bool
+	// EnableIf is the name of an environment variable which must be true for the field to be processed at all.
+	EnableIf string
+	// CSV causes slice, array and map values to be split according to the rules of encoding/csv.
+	CSV bool
+	// Delimiter separates the elements of slice, array and map values, taking precedence over WithDelimiter.
+	Delimiter string
+	// FromFile causes the value to be treated as the path of a file whose contents are the actual value.
+	FromFile bool
+	// Description documents the field, and is surfaced via Describe.
+	Description string
+	// Raw causes the fields of a nested struct, and all of their descendants, to use their Go names verbatim.
+	Raw bool
+	// Epoch is one of "unix", "unixmilli" or "unixnano" when time.Time values should be parsed as Unix timestamps.
+	Epoch string
+	// Len and MaxLen constrain the number of runes in string values.
+	Len       int
+	HasLen    bool
+	MaxLen    int
+	HasMaxLen bool
+	// OneOf restricts values to the given set, compared case-insensitively when IgnoreCase is set.
+	OneOf      []string
+	IgnoreCase bool
+	// Min and Max are the inclusive bounds of numeric values, parsed according to the kind of the field.
+	Min    string
+	HasMin bool
+	Max    string
+	HasMax bool
+	// Unit and Suffix are stripped from numeric values before they are parsed. See unitSetter.
+	Unit   string
+	Suffix string
+	// Trim, Lower and Upper normalize string values before they are set, in that order.
+	Trim  bool
+	Lower bool
+	Upper bool
 }
 
-func parseFieldTag(tag string) fieldTag {
+// delimiter returns the separator of the elements of slice, array and map values, which is the one specified via
+// the field's tag, or otherwise via WithDelimiter, or otherwise defaultDelimiter.
+func (d *decodeState) delimiter(tag fieldTag) string {
+	switch {
+	case tag.Delimiter != "":
+		return tag.Delimiter
+	case d.opts.delimiter != "":
+		return d.opts.delimiter
+	default:
+		return defaultDelimiter
+	}
+}
+
+func parseFieldTag(tag string) (fieldTag, error) {
 	tagParts := strings.SplitN(tag, ",", 2)
 	envName := strings.TrimSpace(tagParts[0])
 	result := fieldTag{Name: envName}
 	if len(tagParts) == 1 {
-		return result
+		return result, nil
 	}
 
-	keyValPairs := make(map[string]string)
+	var (
+		keyValPairs = make(map[string]string)
+		lastKey     string
+	)
+
 	for _, pair := range strings.Split(tagParts[1], " ") {
 		keyVal := strings.SplitN(pair, "=", 2)
 		standardName := strings.ToLower(strings.TrimSpace(keyVal[0]))
-		if strings.EqualFold(standardName, "required") {
+
+		// Every word following the msg or desc options, which hold prose, is part of their text, keywords included.
+		if len(keyVal) == 1 && (lastKey == "msg" || lastKey == "desc") && standardName != "" {
+			keyValPairs[lastKey] += " " + strings.ReplaceAll(pair, "\\s", " ")
+			continue
+		}
+
+		switch standardName {
+		case "required":
 			result.Required = true
+		case "secret":
+			result.Secret = true
+		case "flatten":
+			result.Flatten = true
+		case "json":
+			result.JSON = true
+		case "csv":
+			result.CSV = true
+		case "ossep":
+			result.Delimiter = string(os.PathListSeparator)
+		case "unix", "unixmilli", "unixnano":
+			result.Epoch = standardName
+		case "ignorecase":
+			result.IgnoreCase = true
+		case "fromfile":
+			result.FromFile = true
+		case "raw":
+			result.Raw = true
+		case "range":
+			result.Range = true
+		case "kv":
+			result.KV = true
+		case "indexed":
+			result.Indexed = true
+		case "allowempty":
+			result.AllowEmpty = true
+		case "strict":
+			result.Strict = true
+		case "trim":
+			result.Trim = true
+		case "bytes":
+			result.Unit = unitBytes
+		case "lower":
+			result.Lower = true
+		case "upper":
+			result.Upper = true
+		default:
+			// Any unrecognized word following the oneof option is another allowed value.
+			if len(keyVal) == 1 && lastKey == "oneof" && standardName != "" {
+				result.OneOf = append(result.OneOf, splitOneOf(strings.TrimSpace(pair))...)
+				continue
+			}
+
+			// Likewise, any unrecognized word following the into option is another field.
+			if len(keyVal) == 1 && lastKey == "into" && standardName != "" {
+				result.Into = append(result.Into, strings.TrimSpace(pair))
+				continue
+			}
+		}
+
+		// A keyword following the oneof option is ambiguous, as it may be intended as either an option or a value.
+		if len(keyVal) == 1 && lastKey == "oneof" && standardName != "" {
+			return result, fmt.Errorf("ambiguous %q following the oneof option: place options before oneof, and "+
+				"separate values which are keywords with |, e.g. oneof=text|json", pair)
 		}
 
 		if len(keyVal) != 2 {
 			continue
 		}
 
-		keyValPairs[standardName] = strings.ReplaceAll(keyVal[1], "\\s", " ")
+		lastKey = standardName
+		value := strings.ReplaceAll(keyVal[1], "\\s", " ")
+		switch standardName {
+		case "alias":
+			result.Aliases = append(result.Aliases, value)
+			continue
+		case "oneof":
+			result.OneOf = append(result.OneOf, splitOneOf(keyVal[1])...)
+			continue
+		case "into":
+			result.Into = append(result.Into, value)
+			continue
+		case "delim":
+			result.Delimiter = value
+			continue
+		}
+
+		keyValPairs[standardName] = value
 	}
 
 	result.Default, result.HasDefault = keyValPairs["default"]
-	return result
+	result.RequiredMessage = keyValPairs["msg"]
+	result.DefaultFunc = keyValPairs["defaultfunc"]
+	result.DefaultFrom = keyValPairs["defaultfrom"]
+	result.Prefix = keyValPairs["prefix"]
+	result.ChildSep = keyValPairs["childsep"]
+	result.Fanout = keyValPairs["fanout"]
+	result.Description = keyValPairs["desc"]
+	if unit, ok := keyValPairs["unit"]; ok {
+		result.Unit = strings.ToLower(unit)
+	}
+	result.Suffix = keyValPairs["suffix"]
+	result.Xor = keyValPairs["xor"]
+	result.EnableIf = keyValPairs["enableif"]
+	result.Min, result.HasMin = keyValPairs["min"]
+	result.Max, result.HasMax = keyValPairs["max"]
+
+	if condition, ok := keyValPairs["requiredif"]; ok {
+		condParts := strings.SplitN(condition, "=", 2)
+		if len(condParts) != 2 || condParts[0] == "" {
+			return result, fmt.Errorf("invalid requiredIf option %q, expected NAME=value", condition)
+		}
+		result.RequiredIfVar, result.RequiredIfValue = condParts[0], condParts[1]
+	}
+
+	if err := validateUnit(result.Unit); err != nil {
+		return result, err
+	}
+
+	var err error
+	if result.Len, result.HasLen, err = parseIntOption(keyValPairs, "len"); err != nil {
+		return result, err
+	}
+
+	if result.MaxLen, result.HasMaxLen, err = parseIntOption(keyValPairs, "maxlen"); err != nil {
+		return result, err
+	}
+
+	return result, nil
 }
 
-func processField(field reflect.Value, fieldType reflect.StructField, envVars map[string]string, fieldPathPrefix, envVarPrefix string) error {
-	fTag := parseFieldTag(fieldType.Tag.Get("env"))
+// splitOneOf splits the values of the oneof option, which are separated by |, unescaping \s to a space.
+func splitOneOf(v string) []string {
+	values := strings.Split(v, "|")
+	for i := range values {
+		values[i] = strings.ReplaceAll(values[i], "\\s", " ")
+	}
+	return values
+}
+
+// parseIntOption parses the integer value of the named tag option, if present.
+func parseIntOption(keyValPairs map[string]string, name string) (int, bool, error) {
+	v, ok := keyValPairs[name]
+	if !ok {
+		return 0, false, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s option %q: %w", name, v, err)
+	}
+
+	return n, true, nil
+}
+
+// fieldEnvName returns the name of the environment variable for a field.
+func (d *decodeState) fieldEnvName(fieldType reflect.StructField, fTag fieldTag, s scope) string {
+	if name, ok := d.opts.fieldOverrides[s.fieldPathPrefix+fieldType.Name]; ok {
+		return name
+	}
+
+	if fTag.Name != "" {
+		return fTag.Name
+	}
+
+	name := fieldType.Name
+	if tagName := d.fallbackTagName(fieldType); tagName != "" {
+		name = tagName
+	}
+
+	if s.rawNames {
+		return s.envVarPrefix + name
+	}
+
+	if d.opts.lowercaseNames {
+		return s.envVarPrefix + strings.ToLower(fieldNameToEnvVariable(name))
+	}
+
+	return s.envVarPrefix + fieldNameToEnvVariable(name)
+}
+
+// fallbackTagName returns the name given to the field by the tag provided via WithFallbackTag, stripped of any
+// options following a comma, or the empty string when there is none. Like encoding/json, a name of "-" is ignored.
+func (d *decodeState) fallbackTagName(fieldType reflect.StructField) string {
+	if d.opts.fallbackTag == "" {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(fieldType.Tag.Get(d.opts.fallbackTag), ",")
+	if name == "-" {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// nestedScope returns the scope for the fields of a nested struct.
+func (d *decodeState) nestedScope(fieldType reflect.StructField, fTag fieldTag, fieldPath, envName string, s scope) scope {
+	nested := scope{
+		fieldPathPrefix: fmt.Sprintf("%s.", fieldPath),
+		rawNames:        s.rawNames || fTag.Raw,
+		separator:       s.separator,
+		depth:           s.depth + 1,
+	}
+
+	if fTag.ChildSep != "" {
+		nested.separator = fTag.ChildSep
+	}
+	nested.envVarPrefix = d.scopePrefix(envName, nested)
+
+	// Anonymous embedded structs have their fields promoted, just like Go does, unless a name was explicitly
+	// provided via the env tag, or the tag provided via WithFallbackTag. Flattened structs never add a prefix segment.
+	if fTag.Flatten || (fieldType.Anonymous && fTag.Name == "" && d.fallbackTagName(fieldType) == "") {
+		nested.envVarPrefix = s.envVarPrefix
+	}
+
+	return nested
+}
+
+func (d *decodeState) processField(field reflect.Value, fieldType reflect.StructField, s scope) error {
+	fTag, tagErr := parseFieldTag(fieldType.Tag.Get("env"))
 	envName := fTag.Name
-	if envName == "-" {
+	if envName == "-" || d.opts.ignoreFields[s.fieldPathPrefix+fieldType.Name] {
 		return nil
 	}
 
-	if envName == "" {
-		envName = envVarPrefix + fieldNameToEnvVariable(fieldType.Name)
+	envName = d.fieldEnvName(fieldType, fTag, s)
+	if tagErr == nil && isLazy(fieldType.Type) {
+		// Lazy fields are not looked up until they are read.
+		for _, name := range append([]string{envName}, fTag.Aliases...) {
+			d.known[d.fold(name)] = true
+		}
+
+		d.initLazy(field, fieldType, s)
+		return nil
 	}
 
 	var (
-		envValue, envValueSet = envVars[envName]
-		fieldPath             = fieldPathPrefix + fieldType.Name
+		fieldPath = s.fieldPathPrefix + fieldType.Name
+		names     = append([]string{envName}, fTag.Aliases...)
+		// allowEmpty is set when an empty value is a value, rather than being treated as unset.
+		allowEmpty = fTag.AllowEmpty || !d.opts.emptyAsUnset
+		// sourceEnvName is the name of the environment variable the value was read from, which differs from
+		// envName when the value was read from an alias.
+		envValue, sourceEnvName, envValueSet = d.lookupEmpty(d.source, allowEmpty, names...)
 	)
 
-	if !envValueSet && fTag.HasDefault {
+	if !envValueSet {
+		sourceEnvName = envName
+	}
+
+	// valueSource describes where the value came from, as reported via WithTrace.
+	var valueSource string
+	switch {
+	case envValueSet && sourceEnvName != envName:
+		valueSource = "alias"
+	case envValueSet:
+		valueSource = "env"
+	}
+
+	if fanout, ok := s.fanout[fieldType.Name]; ok && !envValueSet {
+		envValue, sourceEnvName, envValueSet, valueSource = fanout.value, fanout.envVar, true, "fanout"
+	}
+
+	for _, name := range names {
+		d.known[d.fold(name)] = true
+	}
+
+	newErr := func(err error) error {
+		errPath := fieldPath
+		var elemErr elementError
+		if errors.As(err, &elemErr) {
+			errPath = fmt.Sprintf("%s[%s]", fieldPath, elemErr.key)
+			err = elemErr.err
+		}
+
+		if fTag.Secret {
+			return newSecretFieldParseError(err, errPath, sourceEnvName, envValue)
+		}
+		return newFieldParseError(err, errPath, sourceEnvName, envValue)
+	}
+
+	// populated records that the field was set, and invokes the hook provided via WithAfterSet.
+	populated := func() error {
+		d.populated = append(d.populated, fieldPath)
+		if d.opts.afterSet == nil {
+			return nil
+		}
+
+		if err := d.opts.afterSet(fieldPath, sourceEnvName, field, fTag.Secret); err != nil {
+			return newErr(err)
+		}
+		return nil
+	}
+
+	if tagErr != nil {
+		return newErr(tagErr)
+	}
+
+	if fTag.EnableIf != "" {
+		enabled, err := d.isEnabled(fTag.EnableIf)
+		if err != nil {
+			return newErr(err)
+		}
+
+		if !enabled {
+			return nil
+		}
+	}
+
+	// An embedded pointer to a struct has its fields promoted just like an embedded struct, and is only allocated
+	// when one of them is populated.
+	if fieldType.Anonymous && field.Kind() == reflect.Pointer && d.isNestedStruct(field.Type().Elem(), fTag) {
+		nested := d.nestedScope(fieldType, fTag, fieldPath, envName, s)
+		if err := d.checkDepth(nested); err != nil {
+			return newErr(err)
+		}
+
+		elem, numPopulated := field, len(d.populated)
+		if field.IsNil() {
+			elem = reflect.New(field.Type().Elem())
+		}
+
+		if err := d.loadEnvVarsIntoStruct(elem.Elem(), nested); err != nil {
+			return err
+		}
+
+		if field.IsNil() && len(d.populated) > numPopulated {
+			field.Set(elem)
+		}
+		return nil
+	}
+
+	if d.isNestedStruct(field.Type(), fTag) && !d.hasPopulatedFields(field.Type()) {
+		// A struct without fields to populate is left untouched, but requiring it is a misconfiguration, as
+		// no environment could satisfy the requirement.
+		if fTag.Required {
+			return newErr(errors.New("required option is invalid on a struct without exported fields"))
+		}
+		return nil
+	}
+
+	if fTag.Indexed && !envValueSet {
+		if values := d.lookupIndexed(envName); len(values) > 0 {
+			if d.opts.trace != nil {
+				d.opts.trace(fieldPath, d.joinPrefix(envName)+"0", true, "env")
+			}
+
+			setter, err := d.indexedSetter(field.Type(), values)
+			if err != nil {
+				return newErr(err)
+			}
+
+			if err := setter.Set("", field); err != nil {
+				return newErr(err)
+			}
+			return populated()
+		}
+	}
+
+	if d.isStructMap(field.Type()) {
+		found, err := d.loadStructMap(field, fTag, fieldPath, envName, s, newErr)
+		if err != nil {
+			return err
+		}
+
+		if !found && d.isRequired(field.Type(), fTag) {
+			return newErr(ErrRequired)
+		}
+		return nil
+	}
+
+	// readFromFile is set when the value has already been read from a file, and so must not be treated as a path.
+	var readFromFile bool
+	if !envValueSet && d.opts.fileSuffix != "" {
+		fileEnvName := envName + d.opts.fileSuffix
+		d.known[d.fold(fileEnvName)] = true
+		if path, _, ok := d.lookup(d.source, fileEnvName); ok {
+			sourceEnvName = fileEnvName
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return newErr(err)
+			}
+			envValue, envValueSet, readFromFile, valueSource = string(contents), true, true, "file"
+		}
+	}
+
+	// matchedAlias is set when the value was read from an alias, rather than the field's own name.
+	matchedAlias := envValueSet && sourceEnvName != envName
+	for _, fallback := range d.fallbacks {
+		if envValueSet {
+			break
+		}
+
+		if value, name, ok := d.lookupEmpty(fallback, allowEmpty, names...); ok {
+			envValue, sourceEnvName, envValueSet, valueSource = value, name, true, "fallback"
+			matchedAlias = name != envName
+		}
+	}
+
+	if matchedAlias && d.opts.deprecationFunc != nil {
+		d.opts.deprecationFunc(fieldPath, sourceEnvName, envName)
+	}
+
+	// keepPreset is set when the field's current value takes precedence over its defaults.
+	keepPreset := !envValueSet && d.opts.keepPresetValues && !field.IsZero()
+	if !envValueSet && !keepPreset && fTag.DefaultFrom != "" {
+		d.known[d.fold(fTag.DefaultFrom)] = true
+		if value, ok := d.resolvedValue(fTag.DefaultFrom); ok {
+			envValue, sourceEnvName, envValueSet, valueSource = value, fTag.DefaultFrom, true, "defaultFrom"
+		}
+	}
+
+	if !envValueSet && !keepPreset && fTag.HasDefault {
 		envValue = fTag.Default
-		envValueSet = true
+		envValueSet, valueSource = true, "default"
+		if d.opts.expandDefaults {
+			expanded, err := d.expandDefault(envValue)
+			if err != nil {
+				return newErr(err)
+			}
+			envValue = expanded
+		}
+	}
+
+	if !envValueSet && !keepPreset && fTag.DefaultFunc != "" {
+		value, err := callDefaultFunc(fTag.DefaultFunc)
+		if err != nil {
+			return newErr(err)
+		}
+		envValue, envValueSet, valueSource = value, true, "defaultFunc"
+	}
+
+	if value, ok := d.defaults[fieldPath]; ok && !envValueSet && !keepPreset {
+		if d.opts.trace != nil {
+			d.opts.trace(fieldPath, sourceEnvName, true, "defaults")
+		}
+
+		field.Set(value)
+		return populated()
+	}
+
+	if envValueSet && fTag.FromFile && !readFromFile {
+		contents, err := os.ReadFile(envValue)
+		if err != nil {
+			return newErr(err)
+		}
+		envValue = string(contents)
+	}
+
+	if envValueSet && d.opts.trimSpace {
+		envValue = strings.TrimSpace(envValue)
+	}
+
+	if envValueSet {
+		envValue = stripQuotes(envValue, d.opts.quotePairs)
+	}
+
+	if envValueSet {
+		d.resolved[d.fold(envName)] = envValue
+	}
+
+	if fTag.Xor != "" {
+		d.exclusive = append(d.exclusive, exclusiveField{field: fieldPath, envVar: envName, other: fTag.Xor})
+	}
+
+	if d.opts.trace != nil && !d.isNestedStruct(field.Type(), fTag) {
+		d.opts.trace(fieldPath, sourceEnvName, envValueSet, valueSource)
+	}
+
+	if !envValueSet && !keepPreset && d.isRequired(field.Type(), fTag) {
+		if fTag.RequiredMessage != "" {
+			return newErr(fmt.Errorf("%w: %s", ErrRequired, fTag.RequiredMessage))
+		}
+		return newErr(ErrRequired)
+	}
+
+	if !envValueSet && !keepPreset && fTag.RequiredIfVar != "" {
+		if value, _ := d.resolvedValue(fTag.RequiredIfVar); value == fTag.RequiredIfValue {
+			return newErr(requiredIfError{fTag.RequiredIfVar, fTag.RequiredIfValue})
+		}
 	}
 
-	if !envValueSet && fTag.Required {
-		return newFieldParseError(errors.New("missing required value"), fieldPath, envName)
+	if fTag.JSON {
+		if !envValueSet {
+			return nil
+		}
+
+		if err := json.Unmarshal([]byte(envValue), field.Addr().Interface()); err != nil {
+			return newErr(err)
+		}
+
+		return populated()
 	}
 
-	didUnmarshal, err := attemptUnmarshal(field, envValue, envValueSet)
+	didUnmarshal, err := attemptUnmarshal(d.ctx, field, envValue, envValueSet)
 	if err != nil {
-		return newFieldParseError(err, fieldPath, envName)
+		return newErr(err)
 	}
 
 	if didUnmarshal {
+		if envValueSet {
+			return populated()
+		}
 		return nil
 	}
 
-	if field.Kind() == reflect.Struct {
-		return loadEnvVarsIntoStruct(field, envVars, fmt.Sprintf("%s.", fieldPath), fmt.Sprintf("%s_", envName))
+	if fTag.KV {
+		if !envValueSet {
+			return nil
+		}
+		return d.loadKeyValueStruct(field, fTag, fieldPath, envValue, newErr)
+	}
+
+	if d.isNestedStruct(field.Type(), fTag) {
+		nested := d.nestedScope(fieldType, fTag, fieldPath, envName, s)
+		if err := d.checkDepth(nested); err != nil {
+			return newErr(err)
+		}
+		return d.loadEnvVarsIntoStruct(field, nested)
+	}
+
+	fieldValueSetter, err := d.validateFieldAndReturnSetter(field, fTag)
+	if errors.Is(err, ErrUnsupportedType) && d.opts.unsupportedHandler != nil {
+		fieldValueSetter, err = unsupportedHandlerSetter(d.opts.unsupportedHandler), nil
+	}
+
+	if errors.Is(err, ErrUnsupportedType) && d.opts.skipUnsupported {
+		if d.opts.reportSkipped != nil {
+			d.opts.reportSkipped(newErr(err).(FieldParseError))
+		}
+		return nil
 	}
 
-	fieldValueSetter, err := validateFieldAndReturnSetter(field)
 	if err != nil {
-		return newFieldParseError(err, fieldPath, envName)
+		return newErr(err)
+	}
+
+	if validate := tagValidator(fTag); validate != nil {
+		fieldValueSetter = validatingSetter{fieldValueSetter, validate}
 	}
 
 	if !envValueSet {
@@ -216,15 +1408,160 @@ func processField(field reflect.Value, fieldType reflect.StructField, envVars ma
 
 	err = fieldValueSetter.Set(envValue, field)
 	if err != nil {
-		return newFieldParseError(err, fieldPath, envName)
+		return newErr(err)
+	}
+
+	return populated()
+}
+
+// isNestedStruct reports whether a field of type t is a struct whose fields are themselves populated from the
+// environment, rather than a value parsed from a single environment variable.
+func (d *decodeState) isNestedStruct(t reflect.Type, tag fieldTag) bool {
+	return t.Kind() == reflect.Struct && !tag.JSON && !tag.Range && !tag.KV && !isUnmarshaler(t) &&
+		!d.hasTypeParser(t) && !isSQLNull(t) && !isLazy(t)
+}
+
+// isEnabled reports whether the gate variable named by the enableIf option is set to a true value, parsed just like
+// a bool field. An unset gate disables the field, while a value which is not a bool is an error.
+func (d *decodeState) isEnabled(gate string) (bool, error) {
+	d.known[d.fold(gate)] = true
+	value, ok := d.resolvedValue(gate)
+	if !ok {
+		return false, nil
+	}
+
+	parse := strconv.ParseBool
+	if d.opts.boolParser != nil {
+		parse = d.opts.boolParser
+	}
+
+	enabled, err := parse(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for enableIf variable %s: %w", gate, err)
 	}
+	return enabled, nil
+}
 
+// stripQuotes removes a single layer of the first of the quote pairs which both opens and closes v.
+func stripQuotes(v string, pairs [][2]string) string {
+	for _, pair := range pairs {
+		if len(v) >= len(pair[0])+len(pair[1]) && strings.HasPrefix(v, pair[0]) && strings.HasSuffix(v, pair[1]) {
+			return v[len(pair[0]) : len(v)-len(pair[1])]
+		}
+	}
+	return v
+}
+
+// hasPopulatedFields reports whether any field of the struct type t may be populated, which excepting
+// WithAllowUnexported is only the case for exported fields.
+func (d *decodeState) hasPopulatedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() || isEmbeddedStruct(field) || (d.opts.allowUnexported && field.Name != "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// structScope returns the scope for the fields of the struct type t, which is s with the prefix declared by t, if
+// any, appended to its prefix. A prefix is declared via the `prefix` option on a blank field of the struct,
+// e.g. _ struct{} `env:",prefix=APP"`.
+func (d *decodeState) structScope(t reflect.Type, s scope) scope {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name != "_" {
+			continue
+		}
+
+		fTag, _ := parseFieldTag(t.Field(i).Tag.Get("env"))
+		if fTag.Prefix != "" {
+			s.envVarPrefix = d.scopePrefix(s.envVarPrefix+fTag.Prefix, s)
+		}
+	}
+
+	return s
+}
+
+// checkDepth returns an error wrapping ErrMaxDepth when the scope s is nested beyond the maximum depth.
+func (d *decodeState) checkDepth(s scope) error {
+	if s.depth > d.opts.maxDepth {
+		return fmt.Errorf("%w: the maximum depth is %d", ErrMaxDepth, d.opts.maxDepth)
+	}
 	return nil
 }
 
-var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+// scopePrefix joins prefix with the separator of the scope s, or the prefix separator when it has none.
+func (d *decodeState) scopePrefix(prefix string, s scope) string {
+	if s.separator != "" {
+		return joinPrefixWith(prefix, s.separator)
+	}
+	return d.joinPrefix(prefix)
+}
+
+// structField returns the field of the struct v at index i, and whether it may be populated. Unexported fields are
+// only returned when v is addressable, and either WithAllowUnexported is provided or the field is an embedded struct,
+// whose fields are promoted just like encoding/json does. Such fields are made settable via unsafe.
+func (d *decodeState) structField(v reflect.Value, i int) (reflect.Value, bool) {
+	field, fieldType := v.Field(i), v.Type().Field(i)
+	if fieldType.IsExported() {
+		return field, true
+	}
+
+	if !field.CanAddr() || fieldType.Name == "_" || (!d.opts.allowUnexported && !isEmbeddedStruct(fieldType)) {
+		return reflect.Value{}, false
+	}
+
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem(), true
+}
+
+// isEmbeddedStruct reports whether f is an embedded struct, or an embedded pointer to one.
+func isEmbeddedStruct(f reflect.StructField) bool {
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return f.Anonymous && t.Kind() == reflect.Struct
+}
+
+// isRequired reports whether a field of type t must have a value, either because it is tagged as required, or
+// because of WithRequireAll, which never applies to nested structs as their fields are checked individually, nor to
+// fields tagged with the xor option, which are only required when their alternative is not set.
+func (d *decodeState) isRequired(t reflect.Type, tag fieldTag) bool {
+	if tag.Required {
+		return true
+	}
+
+	return d.opts.requireAll && t.Kind() != reflect.Pointer && !tag.HasDefault && tag.DefaultFunc == "" &&
+		tag.Xor == "" && !d.isNestedStruct(t, tag)
+}
+
+var (
+	unmarshalerType        = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	contextUnmarshalerType = reflect.TypeOf((*ContextUnmarshaler)(nil)).Elem()
+)
+
+func implementsUnmarshaler(t reflect.Type) bool {
+	return t.Implements(unmarshalerType) || t.Implements(contextUnmarshalerType)
+}
+
+// isUnmarshaler reports whether a pointer to t, or any type reached by dereferencing t, implements Unmarshaler
+// or ContextUnmarshaler.
+func isUnmarshaler(t reflect.Type) bool {
+	t = reflect.PointerTo(t)
+	for !implementsUnmarshaler(t) {
+		if t.Kind() != reflect.Pointer {
+			return false
+		}
+		t = t.Elem()
+	}
+	return true
+}
+
+func attemptUnmarshal(ctx context.Context, field reflect.Value, envValue string, envValueSet bool) (bool, error) {
+	if field.Kind() == reflect.Interface {
+		return attemptUnmarshalInterface(ctx, field, envValue, envValueSet)
+	}
 
-func attemptUnmarshal(field reflect.Value, envValue string, envValueSet bool) (bool, error) {
 	field = field.Addr()
 	fieldType := field.Type()
 	var (
@@ -232,7 +1569,7 @@ func attemptUnmarshal(field reflect.Value, envValue string, envValueSet bool) (b
 		foundUnmarshaler = true
 	)
 
-	for !fieldType.Implements(unmarshalerType) {
+	for !implementsUnmarshaler(fieldType) {
 		if fieldType.Kind() == reflect.Pointer {
 			fieldType = fieldType.Elem()
 			unmarshalerDepth++
@@ -258,12 +1595,54 @@ func attemptUnmarshal(field reflect.Value, envValue string, envValueSet bool) (b
 		unmarshalerValue = unmarshalerValue.Elem()
 	}
 
-	unmarshaler, isUnmarshaler := unmarshalerValue.Interface().(Unmarshaler)
-	if !isUnmarshaler {
+	switch unmarshaler := unmarshalerValue.Interface().(type) {
+	case ContextUnmarshaler:
+		return true, unmarshaler.UnmarshalEnvContext(ctx, envValue)
+	case Unmarshaler:
+		return true, unmarshaler.UnmarshalEnv(envValue)
+	default:
 		panic("unreachable case: must be unmarshaler")
 	}
+}
+
+// attemptUnmarshalInterface unmarshals into the dynamic value of an interface field, when that value, or a pointer
+// to it, implements Unmarshaler or ContextUnmarshaler. A nil interface is left untouched when no value is set,
+// and is otherwise left to be handled like any other field (e.g. via a factory registered with RegisterFactory).
+func attemptUnmarshalInterface(ctx context.Context, field reflect.Value, envValue string, envValueSet bool) (bool, error) {
+	if field.IsNil() {
+		return !envValueSet, nil
+	}
+
+	elem := field.Elem()
+	if elem.Kind() == reflect.Pointer {
+		if elem.IsNil() || !implementsUnmarshaler(elem.Type()) {
+			return false, nil
+		}
+
+		if !envValueSet {
+			return true, nil
+		}
+
+		return attemptUnmarshal(ctx, elem.Elem(), envValue, true)
+	}
+
+	if !implementsUnmarshaler(reflect.PointerTo(elem.Type())) {
+		return false, nil
+	}
+
+	if !envValueSet {
+		return true, nil
+	}
+
+	// The dynamic value of an interface is not addressable, so a copy is unmarshaled and stored in its place.
+	value := reflect.New(elem.Type()).Elem()
+	value.Set(elem)
+	if _, err := attemptUnmarshal(ctx, value, envValue, true); err != nil {
+		return true, err
+	}
 
-	return true, unmarshaler.UnmarshalEnv(envValue)
+	field.Set(value)
+	return true, nil
 }
 
 func isNum(r rune) bool {
@@ -1,11 +1,18 @@
 package env_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/rad12000/go-env"
+	"math/big"
+	"net"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 )
 
 func ExampleUnmarshalPrefix() {
@@ -35,6 +42,317 @@ func ExampleUnmarshalPrefix() {
 	// {ConnectionString:db connection string User:db user Password:db password TimeoutSeconds:123}
 }
 
+func ExampleUnmarshalPrefixStrict() {
+	var out struct {
+		Port    int
+		Timeout time.Duration
+	}
+
+	environ := []string{"PAYMENTS_PORT=8080", "PAYMENTS_TIMOUT=5s", "PAYMENTS_DEBUG=true", "HOME=/root"}
+	err := env.UnmarshalPrefixStrict(environ, &out, "PAYMENTS")
+	fmt.Println(err)
+	fmt.Println(errors.Is(err, env.ErrUnknownEnvVars))
+
+	// Output:
+	// env: unknown environment variables with prefix "PAYMENTS_": PAYMENTS_DEBUG, PAYMENTS_TIMOUT
+	// true
+}
+
+func ExampleLoad() {
+	var db struct {
+		User           string
+		TimeoutSeconds int
+	}
+
+	revert := Must(SetEnv("DB_USER", "db user", "DB_TIMEOUT_SECONDS", "30"))
+	defer revert()
+
+	err := env.Load(&db, env.WithPrefix("DB_"))
+	fmt.Println(err)
+	fmt.Printf("%+v", db)
+
+	// Output:
+	// <nil>
+	// {User:db user TimeoutSeconds:30}
+}
+
+func ExampleWithPrefixSeparator() {
+	var db struct {
+		User string
+		Pool struct {
+			MaxConns int
+		}
+	}
+
+	revert := Must(SetEnv("DB.USER", "db user", "DB.POOL.MAX_CONNS", "10"))
+	defer revert()
+
+	err := env.Unmarshal(os.Environ(), &db, env.WithPrefix("DB"), env.WithPrefixSeparator("."))
+	fmt.Println(err)
+	fmt.Printf("%+v", db)
+
+	// Output:
+	// <nil>
+	// {User:db user Pool:{MaxConns:10}}
+}
+
+func ExampleUnmarshalPopulated() {
+	var out struct {
+		Retries int
+		Workers int `env:",default=4"`
+		Debug   bool
+		Auth    struct {
+			SigningKey string
+		}
+	}
+
+	populated, err := env.UnmarshalPopulated([]string{"RETRIES=0", "AUTH_SIGNING_KEY=key"}, &out)
+	fmt.Println(err)
+	fmt.Println(populated)
+
+	// Output:
+	// <nil>
+	// [Retries Workers Auth.SigningKey]
+}
+
+func ExampleUnmarshalArgs() {
+	var out struct {
+		Port     int    `env:",default=80"`
+		LogLevel string `env:",default=info"`
+		Token    string `env:",required"`
+	}
+
+	_ = env.Unmarshal([]string{"PORT=8080", "TOKEN=t0k3n"}, &out)
+
+	args := []string{"./server", "--verbose", "--port=9090", "LOG_LEVEL=debug"}
+	err := env.UnmarshalArgs(args, &out, env.WithKeepPresetValues())
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {Port:8080 LogLevel:debug Token:t0k3n}
+}
+
+func ExampleValidateEnv() {
+	var out struct {
+		Port    int
+		APIKey  string `env:"API_KEY,required"`
+		Workers uint
+	}
+
+	err := env.ValidateEnv([]string{"PORT=http", "WORKERS=-1"}, &out)
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// failed to unmarshal environment variables into struct *struct { Port int; APIKey string "env:\"API_KEY,required\""; Workers uint }: failed to unmarshal environment variable "PORT" into field "Port": strconv.Atoi: parsing "http": invalid syntax; failed to unmarshal environment variable "API_KEY" into field "APIKey": missing required value; failed to unmarshal environment variable "WORKERS" into field "Workers": strconv.ParseUint: parsing "-1": invalid syntax
+	// {Port:0 APIKey: Workers:0}
+}
+
+func ExampleAggregateError() {
+	var out struct {
+		Port    int
+		APIKey  string `env:"API_KEY,required"`
+		Workers uint
+	}
+
+	err := env.ValidateEnv([]string{"PORT=http", "WORKERS=-1"}, &out)
+
+	var aggErr env.AggregateError
+	if errors.As(err, &aggErr) {
+		for _, fieldErr := range aggErr.FieldParseErrors() {
+			fmt.Printf("%-8s %-8s %v\n", fieldErr.Field(), fieldErr.EnvVar(), fieldErr.Unwrap())
+		}
+	}
+
+	var fieldErr env.FieldParseError
+	fmt.Println(errors.As(err, &fieldErr), fieldErr.Field())
+
+	// Output:
+	// Port     PORT     strconv.Atoi: parsing "http": invalid syntax
+	// APIKey   API_KEY  missing required value
+	// Workers  WORKERS  strconv.ParseUint: parsing "-1": invalid syntax
+	// true Port
+}
+
+func ExampleWithRequireAll() {
+	type Database struct {
+		Host string
+		Port int `env:",default=5432"`
+	}
+
+	var out struct {
+		Database Database
+		APIKey   string  `env:"API_KEY"`
+		Proxy    *string `env:"PROXY"`
+	}
+
+	err := env.ValidateEnv([]string{"API_KEY=secret"}, &out, env.WithRequireAll())
+	fmt.Println(err)
+
+	// Output:
+	// failed to unmarshal environment variables into struct *struct { Database env_test.Database; APIKey string "env:\"API_KEY\""; Proxy *string "env:\"PROXY\"" }: failed to unmarshal environment variable "DATABASE_HOST" into field "Database.Host": missing required value
+}
+
+func ExampleWithFieldOverrides() {
+	var out struct {
+		Port int `env:"PORT"`
+		Auth struct {
+			SigningKey string
+			Issuer     string
+		}
+	}
+
+	environ := []string{"HTTP_PORT=8080", "JWT_SECRET=secret", "IDP_ISSUER=https://idp.example.com"}
+	err := env.Unmarshal(environ, &out, env.WithFieldOverrides(map[string]string{
+		"Port":            "HTTP_PORT",
+		"Auth":            "IDP",
+		"Auth.SigningKey": "JWT_SECRET",
+	}))
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {Port:8080 Auth:{SigningKey:secret Issuer:https://idp.example.com}}
+}
+
+func ExampleWithTrace() {
+	var out struct {
+		Port    int `env:",default=8080"`
+		Host    string
+		Timeout time.Duration `env:",alias=TIMEOUT_SECS"`
+		DB      struct {
+			Password string
+		}
+	}
+
+	trace := env.WithTrace(func(fieldPath, envVar string, found bool, source string) {
+		fmt.Printf("%s %s %t %q\n", fieldPath, envVar, found, source)
+	})
+
+	err := env.UnmarshalPrefix([]string{"TIMEOUT_SECS=5s", "APP_DB_PASSWORD=secret"}, &out, "APP", trace)
+	fmt.Println(err)
+
+	// Output:
+	// Port APP_PORT true "default"
+	// Host APP_HOST false ""
+	// Timeout TIMEOUT_SECS true "alias"
+	// DB.Password APP_DB_PASSWORD true "env"
+	// <nil>
+}
+
+func ExampleWithDefaultTagExpansion() {
+	var out struct {
+		Host string `env:",default=localhost"`
+		Port int    `env:",default=8080"`
+		Addr string `env:",default=${HOST}:${PORT}"`
+	}
+
+	err := env.Unmarshal([]string{"PORT=9090"}, &out, env.WithDefaultTagExpansion())
+	fmt.Println(err)
+	fmt.Println("addr =", out.Addr)
+
+	// Output:
+	// <nil>
+	// addr = localhost:9090
+}
+
+func ExampleWithDeprecationFunc() {
+	var out struct {
+		Auth struct {
+			SigningKey string `env:"AUTH_SIGNING_KEY,alias=JWT_SECRET"`
+		}
+		Port int `env:"PORT,alias=HTTP_PORT"`
+	}
+
+	logDeprecation := env.WithDeprecationFunc(func(field, used, preferred string) {
+		fmt.Printf("%s: %s is deprecated, use %s instead\n", field, used, preferred)
+	})
+
+	err := env.Unmarshal([]string{"JWT_SECRET=secret", "PORT=8080", "HTTP_PORT=80"}, &out, logDeprecation)
+	fmt.Println(err)
+
+	// Output:
+	// Auth.SigningKey: JWT_SECRET is deprecated, use AUTH_SIGNING_KEY instead
+	// <nil>
+}
+
+func ExampleWithBoolParser() {
+	var out struct {
+		Debug    bool
+		Features map[string]bool
+	}
+
+	yesNo := env.WithBoolParser(func(v string) (bool, error) {
+		switch strings.ToLower(v) {
+		case "yes", "on":
+			return true, nil
+		case "no", "off":
+			return false, nil
+		default:
+			return false, fmt.Errorf("invalid boolean %q", v)
+		}
+	})
+
+	err := env.Unmarshal([]string{"DEBUG=yes", "FEATURES=search=on,beta=off"}, &out, yesNo)
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {Debug:true Features:map[beta:false search:true]}
+}
+
+func ExampleWithSplitFunc() {
+	var out struct {
+		Hosts []string
+		Ports []int
+	}
+
+	separators := regexp.MustCompile(`[\s,;]+`)
+	split := env.WithSplitFunc(func(v string) []string {
+		return separators.Split(v, -1)
+	})
+
+	err := env.Unmarshal([]string{"HOSTS=a.example.com b.example.com;c.example.com", "PORTS=80, 443"}, &out, split)
+	fmt.Println(err)
+	fmt.Printf("%+v", out)
+
+	// Output:
+	// <nil>
+	// {Hosts:[a.example.com b.example.com c.example.com] Ports:[80 443]}
+}
+
+func ExampleWithAfterSet() {
+	var out struct {
+		Host     string
+		Port     int    `env:",default=8080"`
+		Password string `env:",secret"`
+		Timeout  int
+	}
+
+	logApplied := env.WithAfterSet(func(fieldPath, envVar string, value reflect.Value, secret bool) error {
+		if secret {
+			fmt.Printf("%s=[REDACTED] (%s)\n", envVar, fieldPath)
+			return nil
+		}
+
+		fmt.Printf("%s=%v (%s)\n", envVar, value, fieldPath)
+		return nil
+	})
+
+	err := env.Unmarshal([]string{"HOST=localhost", "PASSWORD=hunter2"}, &out, logApplied)
+	fmt.Println(err)
+
+	// Output:
+	// HOST=localhost (Host)
+	// PORT=8080 (Port)
+	// PASSWORD=[REDACTED] (Password)
+	// <nil>
+}
+
 type foo byte
 
 func ExampleUnmarshal_plainStruct() {
@@ -127,6 +445,226 @@ func ExampleUnmarshal_envTags() {
 	// name = John Doe
 }
 
+type CommonConfig struct {
+	LogLevel string
+}
+
+func ExampleUnmarshal_embeddedStruct() {
+	var out struct {
+		CommonConfig
+		Service struct {
+			CommonConfig
+		}
+		Named  CommonConfig
+		Tagged struct {
+			CommonConfig `env:"COMMON"`
+		}
+		Flattened struct {
+			Service struct {
+				CommonConfig
+			}
+		} `env:",flatten"`
+	}
+
+	revert := Must(
+		SetEnv(
+			"LOG_LEVEL", "debug",
+			"SERVICE_LOG_LEVEL", "info",
+			"NAMED_LOG_LEVEL", "warn",
+			"COMMON_LOG_LEVEL", "error",
+		),
+	)
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("log level =", out.LogLevel)
+	fmt.Println("service log level =", out.Service.LogLevel)
+	fmt.Println("named log level =", out.Named.LogLevel)
+	fmt.Println("tagged log level =", out.Tagged.LogLevel)
+	fmt.Println("flattened log level =", out.Flattened.Service.LogLevel)
+
+	// Output:
+	// <nil>
+	// log level = debug
+	// service log level = info
+	// named log level = warn
+	// tagged log level = error
+	// flattened log level = info
+}
+
+func ExampleUnmarshal_slicesAndArrays() {
+	var out struct {
+		Hosts       []string
+		Ports       []*int
+		Version     [4]byte
+		Coordinates [3]float64
+		Labels      map[string]int
+	}
+
+	revert := Must(
+		SetEnv(
+			"HOSTS", "a.example.com,b.example.com",
+			"PORTS", "80,443",
+			"VERSION", "1,2,0,15",
+			"COORDINATES", "1.5,-2,3.25",
+			"LABELS", "team=1,tier=2",
+		),
+	)
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("hosts =", out.Hosts)
+	fmt.Println("ports =", *out.Ports[0], *out.Ports[1])
+	fmt.Println("version =", out.Version)
+	fmt.Println("coordinates =", out.Coordinates)
+	fmt.Println("labels =", out.Labels)
+
+	// Output:
+	// <nil>
+	// hosts = [a.example.com b.example.com]
+	// ports = 80 443
+	// version = [1 2 0 15]
+	// coordinates = [1.5 -2 3.25]
+	// labels = map[team:1 tier:2]
+}
+
+func ExampleUnmarshal_bigNumbers() {
+	var out struct {
+		Supply *big.Int
+		Price  big.Float
+	}
+
+	revert := Must(SetEnv("SUPPLY", "123456789012345678901234567890", "PRICE", "0.125"))
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("supply =", out.Supply)
+	fmt.Println("price =", out.Price.String())
+
+	// Output:
+	// <nil>
+	// supply = 123456789012345678901234567890
+	// price = 0.125
+}
+
+func ExampleUnmarshal_json() {
+	var out struct {
+		Server struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		} `env:"SERVER_CONFIG,json"`
+	}
+
+	revert := Must(SetEnv("SERVER_CONFIG", `{"host": "localhost", "port": 8080}`))
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Printf("%+v", out.Server)
+
+	// Output:
+	// <nil>
+	// {Host:localhost Port:8080}
+}
+
+func ExampleUnmarshal_durations() {
+	var out struct {
+		Timeout  time.Duration
+		Backoffs []time.Duration
+	}
+
+	revert := Must(SetEnv("TIMEOUT", "1m30s", "BACKOFFS", "1s,2s,5s"))
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("timeout =", out.Timeout)
+	fmt.Println("backoffs =", out.Backoffs)
+
+	revertInvalid := Must(SetEnv("BACKOFFS", "1s,2,5s"))
+	defer revertInvalid()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+
+	// Output:
+	// <nil>
+	// timeout = 1m30s
+	// backoffs = [1s 2s 5s]
+	// failed to unmarshal environment variables into struct *struct { Timeout time.Duration; Backoffs []time.Duration }: failed to unmarshal environment variable "BACKOFFS" into field "Backoffs[1]": time: missing unit in duration "2"
+}
+
+func ExampleUnmarshal_time() {
+	var out struct {
+		StartedAt  time.Time
+		ExpiresAt  time.Time  `env:",unix"`
+		UpdatedAt  *time.Time `env:",unixmilli"`
+		ObservedAt time.Time  `env:",unixnano"`
+	}
+
+	revert := Must(
+		SetEnv(
+			"STARTED_AT", "2024-01-02T03:04:05Z",
+			"EXPIRES_AT", "1704164645",
+			"UPDATED_AT", "1704164645123",
+			"OBSERVED_AT", "1704164645123456789",
+		),
+	)
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("started at =", out.StartedAt.UTC().Format(time.RFC3339Nano))
+	fmt.Println("expires at =", out.ExpiresAt.UTC().Format(time.RFC3339Nano))
+	fmt.Println("updated at =", out.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	fmt.Println("observed at =", out.ObservedAt.UTC().Format(time.RFC3339Nano))
+
+	// Output:
+	// <nil>
+	// started at = 2024-01-02T03:04:05Z
+	// expires at = 2024-01-02T03:04:05Z
+	// updated at = 2024-01-02T03:04:05.123Z
+	// observed at = 2024-01-02T03:04:05.123456789Z
+}
+
+func ExampleUnmarshal_network() {
+	var out struct {
+		BindIP   net.IP
+		Subnet   *net.IPNet
+		AllowIPs []net.IP `env:"ALLOW_IPS"`
+	}
+
+	revert := Must(SetEnv("BIND_IP", "127.0.0.1", "SUBNET", "10.1.2.3/8", "ALLOW_IPS", "::1,192.168.0.1"))
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Println("bind ip =", out.BindIP)
+	fmt.Println("subnet =", out.Subnet)
+	fmt.Println("allow ips =", out.AllowIPs)
+
+	// Output:
+	// <nil>
+	// bind ip = 127.0.0.1
+	// subnet = 10.0.0.0/8
+	// allow ips = [::1 192.168.0.1]
+}
+
+func ExampleUnmarshal_raw() {
+	var out struct {
+		ThirdParty struct {
+			ApiKey  string
+			Timeout int `env:"THIRD_PARTY_TIMEOUT"`
+			Inner   struct {
+				MaxRetries int
+			}
+		} `env:",raw"`
+	}
+
+	environ := []string{"THIRD_PARTY_ApiKey=key", "THIRD_PARTY_TIMEOUT=30", "THIRD_PARTY_Inner_MaxRetries=3"}
+	fmt.Println(env.Unmarshal(environ, &out))
+	fmt.Printf("%+v", out.ThirdParty)
+
+	// Output:
+	// <nil>
+	// {ApiKey:key Timeout:30 Inner:{MaxRetries:3}}
+}
+
 func ExampleUnmarshal_error() {
 	var plainStruct struct {
 		UnsupportedType chan struct{}
@@ -143,7 +681,29 @@ func ExampleUnmarshal_error() {
 	// true
 	// UnsupportedType
 	// UNSUPPORTED_TYPE
-	// failed to unmarshal environment variable "UNSUPPORTED_TYPE" into field "UnsupportedType": unsupported field type
+	// failed to unmarshal environment variable "UNSUPPORTED_TYPE" into field "UnsupportedType": unsupported field type chan struct {}
+}
+
+func ExampleUnmarshal_secret() {
+	var out struct {
+		Password int `env:"DB_PASS,secret"`
+	}
+
+	revert := Must(SetEnv("DB_PASS", "hunter2"))
+	defer revert()
+
+	err := env.Unmarshal(os.Environ(), &out)
+	var fieldErr env.FieldParseError
+	fmt.Println(errors.As(err, &fieldErr))
+	fmt.Println(fieldErr.Secret())
+	fmt.Println(fieldErr.Value())
+	fmt.Println(fieldErr.Error())
+
+	// Output:
+	// true
+	// true
+	// [REDACTED]
+	// failed to unmarshal environment variable "DB_PASS" into field "Password": [REDACTED]: invalid syntax
 }
 
 func ExampleUnmarshal_customTypes() {
@@ -183,6 +743,70 @@ func ExampleUnmarshal_implementUnmarshaler() {
 	// 4321
 }
 
+type endpoint struct {
+	Host string
+	Port string
+}
+
+func (e *endpoint) UnmarshalEnv(value string) error {
+	host, port, found := strings.Cut(value, ":")
+	if !found {
+		return fmt.Errorf("missing port in endpoint %q", value)
+	}
+
+	e.Host, e.Port = host, port
+	return nil
+}
+
+func ExampleUnmarshal_sliceOfUnmarshalers() {
+	var out struct {
+		Endpoints []endpoint
+		Backups   []*endpoint
+	}
+
+	revert := Must(SetEnv("ENDPOINTS", "a.example.com:80,b.example.com:443", "BACKUPS", "c.example.com:80,d.example.com"))
+	defer revert()
+
+	fmt.Println(env.Unmarshal(os.Environ(), &out))
+	fmt.Printf("%+v", out.Endpoints)
+
+	// Output:
+	// failed to unmarshal environment variables into struct *struct { Endpoints []env_test.endpoint; Backups []*env_test.endpoint }: failed to unmarshal environment variable "BACKUPS" into field "Backups[1]": missing port in endpoint "d.example.com"
+	// [{Host:a.example.com Port:80} {Host:b.example.com Port:443}]
+}
+
+type secretRef string
+
+func (s *secretRef) UnmarshalEnvContext(ctx context.Context, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	*s = secretRef("resolved " + value)
+	return nil
+}
+
+func ExampleUnmarshalContext() {
+	var out struct {
+		APIKey secretRef `env:"API_KEY"`
+	}
+
+	environ := []string{"API_KEY=vault://api-key"}
+	fmt.Println(env.UnmarshalContext(context.Background(), environ, &out))
+	fmt.Println(out.APIKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := env.UnmarshalContext(ctx, environ, &out)
+	fmt.Println(errors.Is(err, context.Canceled))
+
+	// Output:
+	// <nil>
+	// resolved vault://api-key
+	// true
+}
+
 type sliceUnmarshaler []string
 
 func (s *sliceUnmarshaler) UnmarshalEnv(value string) error {
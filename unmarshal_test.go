@@ -1,7 +1,17 @@
 package env
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFieldNameToEnvVariable(t *testing.T) {
@@ -21,3 +31,1791 @@ func TestFieldNameToEnvVariable(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalArrayLength(t *testing.T) {
+	tt := []struct {
+		value string
+		err   string
+	}{
+		{"1,2,3", ""},
+		{"1,2", "expected 3 elements but got 2"},
+		{"1,2,3,4", "expected 3 elements but got 4"},
+		{"", "expected 3 elements but got 0"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			var out struct {
+				Coordinates [3]int
+			}
+
+			err := Unmarshal([]string{"COORDINATES=" + tc.value}, &out)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.Unwrap().Error(); actual != tc.err {
+				t.Fatalf("Expected %q to equal %q", actual, tc.err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalidTarget(t *testing.T) {
+	var (
+		nilStruct *struct{}
+		notStruct int
+	)
+
+	tt := map[string]any{
+		"nil":              nil,
+		"struct":           struct{}{},
+		"nil struct ptr":   nilStruct,
+		"non struct ptr":   &notStruct,
+		"non struct value": notStruct,
+		"pointer to a ptr": &nilStruct,
+	}
+
+	for name, out := range tt {
+		t.Run(name, func(t *testing.T) {
+			if err := Unmarshal(nil, out); !errors.Is(err, ErrInvalidTarget) {
+				t.Fatalf("Expected %v to be ErrInvalidTarget", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalidBigInt(t *testing.T) {
+	var out struct {
+		Supply *big.Int
+	}
+
+	err := Unmarshal([]string{"SUPPLY=12ab"}, &out)
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError, got %v", err)
+	}
+
+	if fieldErr.Field() != "Supply" {
+		t.Fatalf("Expected %s to equal Supply", fieldErr.Field())
+	}
+
+	if fieldErr.Value() != "12ab" {
+		t.Fatalf("Expected %s to equal 12ab", fieldErr.Value())
+	}
+}
+
+func TestUnmarshalInvalidJSON(t *testing.T) {
+	var out struct {
+		Config struct {
+			Host string
+		} `env:",json"`
+	}
+
+	err := Unmarshal([]string{"CONFIG={"}, &out)
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError, got %v", err)
+	}
+
+	if fieldErr.EnvVar() != "CONFIG" {
+		t.Fatalf("Expected %s to equal CONFIG", fieldErr.EnvVar())
+	}
+}
+
+func TestUnmarshalInvalidUnixTime(t *testing.T) {
+	var out struct {
+		ExpiresAt time.Time `env:",unix"`
+	}
+
+	err := Unmarshal([]string{"EXPIRES_AT=2024-01-02"}, &out)
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError, got %v", err)
+	}
+
+	if fieldErr.Field() != "ExpiresAt" {
+		t.Fatalf("Expected %s to equal ExpiresAt", fieldErr.Field())
+	}
+}
+
+func TestUnmarshalElementFieldPath(t *testing.T) {
+	tt := []struct {
+		env  string
+		path string
+	}{
+		{"HOSTS=1,2,3,x", "Hosts[3]"},
+		{"VERSION=1,x", "Version[1]"},
+		{"LABELS=a=1,b=x", "Labels[b]"},
+		{"LABELS=a=1,b", "Labels"},
+		{"IDS=1,x", "Nested.IDs[1]"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.env, func(t *testing.T) {
+			var out struct {
+				Hosts   []int
+				Version [2]int
+				Labels  map[string]int
+				Nested  struct {
+					IDs []uint `env:"IDS"`
+				}
+			}
+
+			err := Unmarshal([]string{tc.env}, &out)
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.Field(); actual != tc.path {
+				t.Fatalf("Expected %s to equal %s", actual, tc.path)
+			}
+		})
+	}
+}
+
+func TestValidateEnvLeavesOutUntouched(t *testing.T) {
+	out := struct {
+		Port  int
+		Hosts []string
+	}{Port: 80}
+
+	err := ValidateEnv([]string{"PORT=8080", "HOSTS=a,b"}, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != 80 || out.Hosts != nil {
+		t.Fatalf("Expected out to be untouched, got %+v", out)
+	}
+}
+
+func TestValidateEnvCollection(t *testing.T) {
+	hosts := []string{"a"}
+	if err := ValidateEnv([]string{"HOSTS=b,c"}, &hosts, WithPrefix("HOSTS")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(hosts, []string{"a"}) {
+		t.Fatalf("Expected out to be untouched, got %q", hosts)
+	}
+
+	var ports []int
+	if err := ValidateEnv([]string{"PORTS=80,x"}, &ports, WithPrefix("PORTS")); err == nil {
+		t.Fatal("Expected an error for an invalid element")
+	}
+
+	_, err := Diff(nil, &hosts, WithPrefix("HOSTS"))
+	if !errors.Is(err, ErrInvalidTarget) || !strings.Contains(err.Error(), "only structs are supported") {
+		t.Fatalf("Expected Diff to reject a collection, got %v", err)
+	}
+}
+
+func TestValidateEnvLeavesPointedToValuesUntouched(t *testing.T) {
+	type tls struct {
+		Cert *string
+	}
+
+	port, cert := 80, "a.pem"
+	out := struct {
+		Port  *int
+		TLS   tls
+		Level fmt.Stringer
+	}{Port: &port, TLS: tls{Cert: &cert}, Level: &level{name: "info"}}
+
+	err := ValidateEnv([]string{"PORT=9999", "TLS_CERT=b.pem", "LEVEL=debug"}, &out)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != &port || port != 80 || cert != "a.pem" || out.Level.String() != "info" {
+		t.Fatalf("Expected out to be untouched, got %d %s %s", port, cert, out.Level)
+	}
+}
+
+type cyclicNode struct {
+	Name string
+	Next *cyclicNode
+}
+
+type embeddedCycle struct {
+	Port int
+	*embeddedCycle
+}
+
+func TestValidateEnvCyclicPointers(t *testing.T) {
+	node := &cyclicNode{Name: "a"}
+	node.Next = node
+
+	if err := Unmarshal([]string{"NAME=b"}, &cyclicNode{Next: node}, WithSkipUnsupported()); err != nil {
+		t.Fatalf("Expected no error from Unmarshal, got %v", err)
+	}
+
+	if err := ValidateEnv([]string{"NAME=b"}, node, WithSkipUnsupported()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if node.Name != "a" || node.Next != node {
+		t.Fatalf("Expected out to be untouched, got %+v", node)
+	}
+
+	cycle := &embeddedCycle{Port: 80}
+	cycle.embeddedCycle = cycle
+	if err := ValidateEnv([]string{"PORT=8080"}, cycle, WithMaxDepth(3)); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("Expected ErrMaxDepth, got %v", err)
+	}
+
+	if cycle.Port != 80 || cycle.embeddedCycle != cycle {
+		t.Fatalf("Expected out to be untouched, got %+v", cycle)
+	}
+}
+
+func TestUnmarshalSecretRedaction(t *testing.T) {
+	tt := []struct {
+		name string
+		out  any
+		env  string
+	}{
+		{"scalar", &struct {
+			Pin int `env:"PIN,secret"`
+		}{}, "PIN=hunter2"},
+		{"slice element", &struct {
+			Pins []int `env:"PINS,secret"`
+		}{}, "PINS=1,hunter2"},
+		{"map value", &struct {
+			M map[string]int `env:"M,secret"`
+		}{}, "M=pw=hunter2"},
+		{"unit", &struct {
+			R float64 `env:"R,secret unit=percent"`
+		}{}, "R=hunter2%"},
+		{"suffix", &struct {
+			N int `env:"N,secret suffix=ms"`
+		}{}, "N=hunter2ms"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Unmarshal([]string{tc.env}, tc.out)
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) || !fieldErr.Secret() {
+				t.Fatalf("Expected a secret FieldParseError, got %v", err)
+			}
+
+			if strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), redactedValue) {
+				t.Fatalf("Expected the value to be redacted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRequiredMessage(t *testing.T) {
+	tt := []struct {
+		tag string
+		err string
+	}{
+		{`env:",required"`, "missing required value"},
+		{`env:",required msg=API\\skey\\sfrom\\sthe\\sdashboard"`, "missing required value: API key from the dashboard"},
+		{`env:",required msg=API key from the dashboard"`, "missing required value: API key from the dashboard"},
+		{`env:",required msg=key is required for secret calls"`, "missing required value: key is required for secret calls"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.tag, func(t *testing.T) {
+			fieldType := reflect.StructOf([]reflect.StructField{
+				{Name: "APIKey", Type: reflect.TypeOf(""), Tag: reflect.StructTag(tc.tag)},
+			})
+
+			err := Unmarshal(nil, reflect.New(fieldType).Interface())
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.Unwrap().Error(); actual != tc.err {
+				t.Fatalf("Expected %q to equal %q", actual, tc.err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRequiredIf(t *testing.T) {
+	type config struct {
+		TLSEnabled bool   `env:"TLS_ENABLED,default=false"`
+		CertFile   string `env:"CERT_FILE,requiredIf=TLS_ENABLED=true"`
+	}
+
+	tt := []struct {
+		name string
+		env  []string
+		err  string
+	}{
+		{"condition met and missing", []string{"TLS_ENABLED=true"}, "missing value required when TLS_ENABLED=true"},
+		{"condition met and set", []string{"TLS_ENABLED=true", "CERT_FILE=cert.pem"}, ""},
+		{"condition not met", []string{"TLS_ENABLED=false"}, ""},
+		{"condition not met by default", nil, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			err := Unmarshal(tc.env, &out)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) || fieldErr.EnvVar() != "CERT_FILE" {
+				t.Fatalf("Expected a FieldParseError for CERT_FILE, got %v", err)
+			}
+
+			if actual := fieldErr.Unwrap().Error(); actual != tc.err {
+				t.Fatalf("Expected %q to equal %q", actual, tc.err)
+			}
+		})
+	}
+
+	var invalid struct {
+		CertFile string `env:",requiredIf=TLS_ENABLED"`
+	}
+
+	if err := Unmarshal(nil, &invalid); err == nil {
+		t.Fatal("Expected an error for a requiredIf option without a value")
+	}
+}
+
+func TestUnmarshalCaseInsensitive(t *testing.T) {
+	type config struct {
+		Port int
+		Auth struct {
+			SigningKey string
+		}
+		Token string `env:"API_TOKEN"`
+	}
+
+	tt := []struct {
+		name   string
+		prefix string
+		env    []string
+	}{
+		{"no prefix", "", []string{"port=8080", "Auth_Signing_Key=key", "api_token=token"}},
+		{"lower case prefix", "app", []string{"APP_PORT=8080", "APP_AUTH_SIGNING_KEY=key", "API_TOKEN=token"}},
+		{"upper case prefix", "APP", []string{"app_port=8080", "app_auth_signing_key=key", "api_token=token"}},
+		{"mixed case prefix", "My_App_", []string{"MY_APP_PORT=8080", "my_app_Auth_SIGNING_key=key", "Api_Token=token"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			if err := UnmarshalPrefix(tc.env, &out, tc.prefix, WithCaseInsensitive()); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.Port != 8080 || out.Auth.SigningKey != "key" || out.Token != "token" {
+				t.Fatalf("Expected all fields to be set, got %+v", out)
+			}
+		})
+	}
+
+	var out config
+	if err := UnmarshalPrefix([]string{"app_port=8080"}, &out, "APP"); err != nil || out.Port != 0 {
+		t.Fatalf("Expected names to be case-sensitive by default, got %+v", out)
+	}
+}
+
+func TestUnmarshalPresetValues(t *testing.T) {
+	type config struct {
+		Host    string `env:",default=localhost"`
+		Port    int    `env:",default=8080"`
+		Timeout int
+		Region  string `env:",default=us-east-1"`
+	}
+
+	tt := []struct {
+		name     string
+		opts     []Option
+		expected config
+	}{
+		{"defaults win", nil, config{Host: "localhost", Port: 9090, Timeout: 30, Region: "us-east-1"}},
+		{"preset values win", []Option{WithKeepPresetValues()}, config{Host: "example.com", Port: 9090, Timeout: 30, Region: "us-east-1"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			out := config{Host: "example.com", Port: 80, Timeout: 30}
+			if err := Unmarshal([]string{"PORT=9090"}, &out, tc.opts...); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out != tc.expected {
+				t.Fatalf("Expected %+v, got %+v", tc.expected, out)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPresetValuesSatisfyRequired(t *testing.T) {
+	out := struct {
+		TLS   bool
+		Token string `env:",required"`
+		Cert  string `env:",requiredIf=TLS=true"`
+		Level string
+	}{Token: "t0k3n", Cert: "cert.pem"}
+
+	if err := Unmarshal([]string{"TLS=true"}, &out); !errors.Is(err, ErrRequired) {
+		t.Fatalf("Expected ErrRequired without WithKeepPresetValues, got %v", err)
+	}
+
+	err := Unmarshal([]string{"TLS=true", "LEVEL=debug"}, &out, WithKeepPresetValues())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Token != "t0k3n" || out.Cert != "cert.pem" || out.Level != "debug" {
+		t.Fatalf("Expected preset values to be kept, got %+v", out)
+	}
+}
+
+func TestUnmarshalIgnoreFields(t *testing.T) {
+	type config struct {
+		Port int
+		Auth struct {
+			SigningKey string
+			Issuer     string
+		}
+		DB struct {
+			Host string
+		}
+	}
+
+	out := config{Port: 80}
+	env := []string{"PORT=8080", "AUTH_SIGNING_KEY=key", "AUTH_ISSUER=issuer", "DB_HOST=db"}
+	if err := Unmarshal(env, &out, WithIgnoreFields("Port", "Auth.SigningKey"), WithIgnoreFields("DB")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != 80 || out.Auth.SigningKey != "" || out.Auth.Issuer != "issuer" || out.DB.Host != "" {
+		t.Fatalf("Expected ignored fields to be left untouched, got %+v", out)
+	}
+}
+
+func TestUnmarshalAliases(t *testing.T) {
+	tt := []struct {
+		name     string
+		env      []string
+		expected string
+	}{
+		{"primary wins", []string{"NEW_NAME=new", "OLD_NAME=old", "LEGACY_NAME=legacy"}, "new"},
+		{"first alias wins", []string{"OLD_NAME=old", "LEGACY_NAME=legacy"}, "old"},
+		{"second alias", []string{"LEGACY_NAME=legacy"}, "legacy"},
+		{"default", nil, "default"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out struct {
+				Name string `env:"NEW_NAME,alias=OLD_NAME alias=LEGACY_NAME default=default"`
+			}
+
+			if err := Unmarshal(tc.env, &out); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.Name != tc.expected {
+				t.Fatalf("Expected %s to equal %s", out.Name, tc.expected)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTypedDefaults(t *testing.T) {
+	type config struct {
+		Timeout  time.Duration   `env:",default=30s"`
+		Backoffs []time.Duration `env:",default=1s,2s"`
+		Since    time.Time       `env:",default=2024-01-02T03:04:05Z"`
+		Addr     net.IP          `env:",default=127.0.0.1"`
+		Total    *big.Int        `env:",default=0x10"`
+	}
+
+	var fromDefaults, fromEnv config
+	if err := Unmarshal(nil, &fromDefaults); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	env := []string{"TIMEOUT=30s", "BACKOFFS=1s,2s", "SINCE=2024-01-02T03:04:05Z", "ADDR=127.0.0.1", "TOTAL=0x10"}
+	if err := Unmarshal(env, &fromEnv); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(fromDefaults, fromEnv) {
+		t.Fatalf("Expected defaults %+v to equal values %+v", fromDefaults, fromEnv)
+	}
+
+	var invalid struct {
+		Timeout time.Duration `env:",default=30"`
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal(nil, &invalid); !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError for an invalid default, got %v", err)
+	}
+}
+
+func TestUnmarshalAliasError(t *testing.T) {
+	var out struct {
+		Port int `env:"PORT,alias=LEGACY_PORT"`
+	}
+
+	err := Unmarshal([]string{"LEGACY_PORT=http"}, &out)
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError, got %v", err)
+	}
+
+	if fieldErr.EnvVar() != "LEGACY_PORT" {
+		t.Fatalf("Expected %s to equal LEGACY_PORT", fieldErr.EnvVar())
+	}
+}
+
+func TestUnmarshalDefaultTagExpansionUnresolved(t *testing.T) {
+	var out struct {
+		Addr string `env:",default=${HOST}:${PORT}"`
+		Host string
+		Port int
+	}
+
+	err := Unmarshal([]string{"HOST=localhost"}, &out, WithDefaultTagExpansion())
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError, got %v", err)
+	}
+
+	expected := "default value references unresolved fields HOST, PORT"
+	if actual := fieldErr.Unwrap().Error(); actual != expected {
+		t.Fatalf("Expected %q to equal %q", actual, expected)
+	}
+}
+
+func TestUnmarshalInvalidNetwork(t *testing.T) {
+	tt := []string{"BIND_IP=localhost", "SUBNET=10.0.0.0"}
+	for _, tc := range tt {
+		t.Run(tc, func(t *testing.T) {
+			var out struct {
+				BindIP net.IP
+				Subnet net.IPNet
+			}
+
+			err := Unmarshal([]string{tc}, &out)
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalNestedEnvVarNames(t *testing.T) {
+	type auth struct {
+		SigningKey string `env:",required"`
+	}
+
+	tt := []struct {
+		tag      string
+		expected string
+	}{
+		{``, "AUTH_SIGNING_KEY"},
+		{`env:"AUTH"`, "AUTH_SIGNING_KEY"},
+		{`env:"AUTH_"`, "AUTH_SIGNING_KEY"},
+		{`env:"JWT_AUTH"`, "JWT_AUTH_SIGNING_KEY"},
+		{`env:"JWT_AUTH_"`, "JWT_AUTH_SIGNING_KEY"},
+		{`env:"JWT_AUTH__"`, "JWT_AUTH_SIGNING_KEY"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.tag, func(t *testing.T) {
+			outType := reflect.StructOf([]reflect.StructField{
+				{Name: "Auth", Type: reflect.TypeOf(auth{}), Tag: reflect.StructTag(tc.tag)},
+			})
+
+			err := Unmarshal(nil, reflect.New(outType).Interface())
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.EnvVar(); actual != tc.expected {
+				t.Fatalf("Expected %s to equal %s", actual, tc.expected)
+			}
+
+			if err := Unmarshal([]string{tc.expected + "=key"}, reflect.New(outType).Interface()); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+type embeddedHost struct {
+	Host string
+}
+
+type EmbeddedPort struct {
+	Port int
+}
+
+func TestUnmarshalEmbeddedStructs(t *testing.T) {
+	var out struct {
+		embeddedHost
+		*EmbeddedPort
+	}
+
+	if err := Unmarshal(nil, &out); err != nil || out.EmbeddedPort != nil {
+		t.Fatalf("Expected an unpopulated embedded pointer to be left nil, got %v, %+v", err, out.EmbeddedPort)
+	}
+
+	if err := Unmarshal([]string{"HOST=localhost", "PORT=8080"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Host != "localhost" || out.EmbeddedPort == nil || out.Port != 8080 {
+		t.Fatalf("Expected the fields of embedded structs to be promoted, got %+v", out)
+	}
+
+	vars, err := Marshal(out)
+	if expected := []string{"HOST=localhost", "PORT=8080"}; err != nil || !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("Expected %q, got %q, %v", expected, vars, err)
+	}
+}
+
+func TestUnmarshalPrefixSeparators(t *testing.T) {
+	type config struct {
+		Port int
+		Auth struct {
+			SigningKey string
+		}
+	}
+
+	tt := []struct {
+		prefix string
+		env    []string
+	}{
+		{"APP", []string{"APP_PORT=8080", "APP_AUTH_SIGNING_KEY=key"}},
+		{"APP_", []string{"APP_PORT=8080", "APP_AUTH_SIGNING_KEY=key"}},
+		{"APP__", []string{"APP_PORT=8080", "APP_AUTH_SIGNING_KEY=key"}},
+		{"_", []string{"PORT=8080", "AUTH_SIGNING_KEY=key"}},
+		{"", []string{"PORT=8080", "AUTH_SIGNING_KEY=key"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.prefix, func(t *testing.T) {
+			var out config
+			if err := UnmarshalPrefix(tc.env, &out, tc.prefix); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.Port != 8080 || out.Auth.SigningKey != "key" {
+				t.Fatalf("Expected all fields to be set, got %+v", out)
+			}
+		})
+	}
+}
+
+func TestUnmarshalChildSeparator(t *testing.T) {
+	type pool struct {
+		MaxConns int
+	}
+
+	type config struct {
+		Port int
+		DB   struct {
+			Host string
+			Pool pool
+			TLS  struct {
+				_    struct{} `env:",prefix=SSL"`
+				Mode string
+			} `env:",childsep=__"`
+		} `env:",childsep=."`
+		Cache struct {
+			Pool pool
+		}
+	}
+
+	env := []string{
+		"APP_PORT=8080", "APP_DB.HOST=db", "APP_DB.POOL.MAX_CONNS=10", "APP_DB.TLS__SSL__MODE=verify",
+		"APP_CACHE_POOL_MAX_CONNS=5",
+	}
+
+	var out config
+	if err := UnmarshalPrefix(env, &out, "APP"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != 8080 || out.DB.Host != "db" || out.DB.Pool.MaxConns != 10 || out.DB.TLS.Mode != "verify" ||
+		out.Cache.Pool.MaxConns != 5 {
+		t.Fatalf("Expected all fields to be set, got %+v", out)
+	}
+
+	vars, err := MarshalPrefix(out, "APP")
+	if err != nil || !reflect.DeepEqual(vars, env) {
+		t.Fatalf("Expected %q, got %q (%v)", env, vars, err)
+	}
+}
+
+func TestUnmarshalTrimSpace(t *testing.T) {
+	var out struct {
+		Port    int
+		Name    string
+		Workers int `env:",default=\\s4\\s"`
+	}
+
+	if err := Unmarshal([]string{"PORT= 8080\n", "NAME=\tweb "}, &out, WithTrimSpace()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != 8080 || out.Name != "web" || out.Workers != 4 {
+		t.Fatalf("Expected values to be trimmed, got %+v", out)
+	}
+
+	if err := Unmarshal([]string{"PORT= 8080\n"}, &out); err == nil {
+		t.Fatal("Expected an error without WithTrimSpace")
+	}
+}
+
+func TestUnmarshalFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tls.key")
+	if err := os.WriteFile(path, []byte("secret key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		TLSKey string `env:"TLS_KEY,fromFile"`
+	}
+
+	if err := Unmarshal([]string{"TLS_KEY=" + path}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.TLSKey != "secret key" {
+		t.Fatalf("Expected %q to equal %q", out.TLSKey, "secret key")
+	}
+
+	err := Unmarshal([]string{"TLS_KEY=" + path + ".missing"}, &out)
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a FieldParseError wrapping os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestUnmarshalFileSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("from file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name     string
+		env      []string
+		expected string
+	}{
+		{"direct", []string{"DB_PASSWORD=direct", "DB_PASSWORD_FILE=" + path}, "direct"},
+		{"file", []string{"DB_PASSWORD_FILE=" + path}, "from file"},
+		{"default", nil, "default"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out struct {
+				DBPassword string `env:"DB_PASSWORD,default=default"`
+			}
+
+			if err := Unmarshal(tc.env, &out, WithFileSuffix("_FILE")); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.DBPassword != tc.expected {
+				t.Fatalf("Expected %q to equal %q", out.DBPassword, tc.expected)
+			}
+		})
+	}
+
+	var out struct {
+		DBPassword string `env:"DB_PASSWORD"`
+	}
+
+	err := Unmarshal([]string{"DB_PASSWORD_FILE=" + path + ".missing"}, &out, WithFileSuffix("_FILE"))
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a FieldParseError wrapping os.ErrNotExist, got %v", err)
+	}
+
+	if fieldErr.EnvVar() != "DB_PASSWORD_FILE" {
+		t.Fatalf("Expected %s to equal DB_PASSWORD_FILE", fieldErr.EnvVar())
+	}
+}
+
+func TestUnmarshalNestedUnsupportedType(t *testing.T) {
+	var out struct {
+		Auth struct {
+			Inner struct {
+				Weird func()
+			}
+		}
+	}
+
+	err := Unmarshal(nil, &out)
+	var fieldErr FieldParseError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldParseError, got %v", err)
+	}
+
+	if fieldErr.Field() != "Auth.Inner.Weird" {
+		t.Fatalf("Expected %s to equal Auth.Inner.Weird", fieldErr.Field())
+	}
+
+	if fieldErr.EnvVar() != "AUTH_INNER_WEIRD" {
+		t.Fatalf("Expected %s to equal AUTH_INNER_WEIRD", fieldErr.EnvVar())
+	}
+
+	if actual := fieldErr.Unwrap().Error(); actual != "unsupported field type func()" {
+		t.Fatalf("Expected %q to equal %q", actual, "unsupported field type func()")
+	}
+}
+
+func TestUnmarshalUnsupportedHandler(t *testing.T) {
+	var out struct {
+		Signal complex128
+		Other  complex64
+		Port   int
+	}
+
+	var calls int
+	handler := WithUnsupportedHandler(func(field reflect.Value, value string) error {
+		calls++
+		if field.Kind() != reflect.Complex128 {
+			return errors.New("only complex128 is handled")
+		}
+
+		c, err := strconv.ParseComplex(value, 128)
+		field.SetComplex(c)
+		return err
+	})
+
+	if err := Unmarshal([]string{"SIGNAL=1+2i", "PORT=80"}, &out, handler); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Signal != complex(1, 2) || out.Port != 80 || calls != 1 {
+		t.Fatalf("Expected only Signal to be set by the handler, got %+v after %d calls", out, calls)
+	}
+
+	var fieldErr FieldParseError
+	err := Unmarshal([]string{"OTHER=1"}, &out, handler)
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "Other" {
+		t.Fatalf("Expected a FieldParseError for Other, got %v", err)
+	}
+
+	calls = 0
+	misconfigured := []any{
+		&struct {
+			Hosts []string `env:",delim=:: csv"`
+		}{},
+		&struct {
+			Span struct{ Low, Mid, High int } `env:",range"`
+		}{},
+	}
+
+	for _, out := range misconfigured {
+		err := Unmarshal([]string{"HOSTS=a::b", "SPAN=1-2"}, out, handler)
+		if !errors.As(err, &fieldErr) || errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("Expected the tag error of %T to be returned, got %v", out, err)
+		}
+	}
+
+	if calls != 0 {
+		t.Fatalf("Expected the handler not to be called for misconfigured fields, got %d calls", calls)
+	}
+}
+
+func TestUnmarshalSkipUnsupported(t *testing.T) {
+	type thirdParty struct {
+		Done     chan struct{}
+		Callback func()
+		Name     string
+	}
+
+	var out struct {
+		thirdParty
+		Signal complex128
+		Port   int
+		Hosts  []string `env:",delim=:: csv"`
+	}
+
+	env := []string{"DONE=x", "CALLBACK=y", "NAME=name", "SIGNAL=1+2i", "PORT=80"}
+	if err := Unmarshal(env, &out); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("Expected an unsupported field type error, got %v", err)
+	}
+
+	out.Hosts = nil
+	err := Unmarshal(env, &out, WithSkipUnsupported(), WithAllowUnexported())
+	if err == nil || errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("Expected only errors other than unsupported types to be reported, got %v", err)
+	}
+
+	var supported struct {
+		thirdParty
+		Signal complex128
+		Port   int
+	}
+
+	if err := Unmarshal(env, &supported, WithSkipUnsupported(), WithAllowUnexported()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if supported.Name != "name" || supported.Port != 80 || supported.Done != nil || supported.Signal != 0 {
+		t.Fatalf("Expected only supported fields to be set, got %+v", supported)
+	}
+
+	vars, err := Marshal(supported, WithSkipUnsupported(), WithAllowUnexported())
+	if err != nil || !reflect.DeepEqual(vars, []string{"NAME=name", "PORT=80"}) {
+		t.Fatalf("Expected unsupported fields to be skipped, got %q (%v)", vars, err)
+	}
+}
+
+func TestUnmarshalSkipUnsupportedFunc(t *testing.T) {
+	var out struct {
+		Done   chan struct{}
+		Signal *complex64 `env:"SIGNAL,secret"`
+		Port   int
+	}
+
+	var skipped []string
+	report := func(err FieldParseError) {
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("Expected an unsupported field type error, got %v", err)
+		}
+		skipped = append(skipped, fmt.Sprintf("%s=%s", err.Field(), err.Value()))
+	}
+
+	if err := Unmarshal([]string{"SIGNAL=1+2i", "PORT=80"}, &out, WithSkipUnsupportedFunc(report)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Port != 80 || !reflect.DeepEqual(skipped, []string{"Done=", "Signal=" + redactedValue}) {
+		t.Fatalf("Expected the unsupported fields to be reported, got %q", skipped)
+	}
+
+	skipped = nil
+	if _, err := Marshal(out, WithSkipUnsupportedFunc(report)); err != nil || len(skipped) != 1 {
+		t.Fatalf("Expected Marshal to report the skipped field, got %q (%v)", skipped, err)
+	}
+}
+
+func TestUnmarshalFallbackPrecedence(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME,alias=LEGACY_NAME default=default"`
+	}
+
+	var (
+		shared    = map[string]string{"NAME": "shared"}
+		sharedOld = map[string]string{"LEGACY_NAME": "shared legacy"}
+		overrides = map[string]string{"NAME": "overrides"}
+	)
+
+	tt := []struct {
+		name     string
+		env      []string
+		opts     []Option
+		expected string
+	}{
+		{"env", []string{"NAME=env"}, []Option{WithFallback(overrides)}, "env"},
+		{"env alias", []string{"LEGACY_NAME=env legacy"}, []Option{WithFallback(overrides)}, "env legacy"},
+		{"first fallback", nil, []Option{WithFallback(overrides), WithFallback(shared)}, "overrides"},
+		{"second fallback", nil, []Option{WithFallback(map[string]string{}), WithFallback(shared)}, "shared"},
+		{"fallback alias", nil, []Option{WithFallback(sharedOld)}, "shared legacy"},
+		{"default", nil, []Option{WithFallback(map[string]string{})}, "default"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			if err := Unmarshal(tc.env, &out, tc.opts...); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.Name != tc.expected {
+				t.Fatalf("Expected %q to equal %q", out.Name, tc.expected)
+			}
+		})
+	}
+}
+
+// level is an Unmarshaler with a value receiver for its String method, and a pointer receiver for UnmarshalEnv.
+type level struct {
+	name string
+}
+
+func (l *level) UnmarshalEnv(v string) error {
+	if v == "" {
+		return errors.New("empty level")
+	}
+	l.name = v
+	return nil
+}
+
+func (l level) String() string {
+	return l.name
+}
+
+func TestUnmarshalInterfaceHoldingUnmarshaler(t *testing.T) {
+	var out struct {
+		Level   fmt.Stringer
+		Pointer fmt.Stringer
+		Unset   fmt.Stringer
+		Nil     fmt.Stringer
+	}
+
+	out.Level = level{name: "info"}
+	out.Pointer = &level{name: "info"}
+	out.Unset = level{name: "info"}
+
+	if err := Unmarshal([]string{"LEVEL=debug", "POINTER=warn"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Level != (level{name: "debug"}) || out.Pointer.String() != "warn" || out.Unset.String() != "info" {
+		t.Fatalf("Expected the dynamic values to be unmarshaled, got %+v", out)
+	}
+
+	if out.Nil != nil {
+		t.Fatalf("Expected a nil interface to remain nil, got %v", out.Nil)
+	}
+
+	var fieldErr FieldParseError
+	if err := Unmarshal([]string{"LEVEL="}, &out); !errors.As(err, &fieldErr) || fieldErr.Field() != "Level" {
+		t.Fatalf("Expected a FieldParseError for Level, got %v", err)
+	}
+
+	if err := Unmarshal([]string{"NIL=debug"}, &out); !errors.As(err, &fieldErr) || fieldErr.Field() != "Nil" {
+		t.Fatalf("Expected a FieldParseError for a nil interface with a value, got %v", err)
+	}
+}
+
+func TestUnmarshalEmptyNestedStruct(t *testing.T) {
+	type unexported struct {
+		name string
+	}
+
+	var out struct {
+		Empty      struct{}
+		Defaulted  struct{} `env:",default=ignored"`
+		Unexported unexported
+		Port       int
+	}
+
+	if err := Unmarshal([]string{"EMPTY=ignored", "UNEXPORTED_NAME=ignored", "PORT=80"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Unexported.name != "" || out.Port != 80 {
+		t.Fatalf("Expected empty structs to be left untouched, got %+v", out)
+	}
+
+	var required struct {
+		Empty struct{} `env:",required"`
+	}
+
+	var fieldErr FieldParseError
+	for _, env := range [][]string{nil, {"EMPTY=set"}} {
+		err := Unmarshal(env, &required)
+		if !errors.As(err, &fieldErr) || fieldErr.Field() != "Empty" || !strings.Contains(err.Error(), "without exported fields") {
+			t.Fatalf("Expected a FieldParseError for a required empty struct, got %v", err)
+		}
+	}
+}
+
+func TestUnmarshalRejectDuplicates(t *testing.T) {
+	var out struct {
+		Host string
+		Port int
+	}
+
+	env := []string{"HOST=a", "PORT=1", "HOST=b", "port=2", "HOST=c"}
+	if err := Unmarshal(env, &out); err != nil || out.Host != "c" {
+		t.Fatalf("Expected the last value to win by default, got %+v (%v)", out, err)
+	}
+
+	err := Unmarshal(env, &out, WithRejectDuplicates())
+	if !errors.Is(err, ErrDuplicateEnvVars) || !strings.HasSuffix(err.Error(), ": HOST") {
+		t.Fatalf("Expected ErrDuplicateEnvVars for HOST, got %v", err)
+	}
+
+	err = Unmarshal(env, &out, WithRejectDuplicates(), WithCaseInsensitive())
+	if !errors.Is(err, ErrDuplicateEnvVars) || !strings.HasSuffix(err.Error(), ": HOST, PORT") {
+		t.Fatalf("Expected ErrDuplicateEnvVars for HOST and PORT, got %v", err)
+	}
+
+	if err := Unmarshal([]string{"HOST=a", "PORT=1"}, &out, WithRejectDuplicates()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestUnmarshalXor(t *testing.T) {
+	type config struct {
+		Cert     string `env:",xor=CERT_FILE"`
+		CertFile string `env:",xor=CERT"`
+		Token    string `env:",xor=LEGACY_TOKEN"`
+	}
+
+	tt := []struct {
+		name string
+		env  []string
+		err  string
+	}{
+		{"first set", []string{"CERT=pem", "TOKEN=t"}, ""},
+		{"second set", []string{"CERT_FILE=cert.pem", "TOKEN=t"}, ""},
+		{"unrelated variable", []string{"CERT=pem", "LEGACY_TOKEN=t"}, ""},
+		{"both set", []string{"CERT=pem", "CERT_FILE=cert.pem", "TOKEN=t"}, "CERT and CERT_FILE are mutually exclusive"},
+		{"neither set", []string{"TOKEN=t"}, "exactly one of CERT and CERT_FILE must be set"},
+		{"unrelated both set", []string{"CERT=pem", "TOKEN=t", "LEGACY_TOKEN=t"}, "TOKEN and LEGACY_TOKEN are mutually exclusive"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			err := Unmarshal(tc.env, &out)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Expected a FieldParseError containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if err := Unmarshal([]string{"CERT=pem", "TOKEN=t"}, &config{}, WithRequireAll()); err != nil {
+		t.Fatalf("Expected xor fields to be exempt from WithRequireAll, got %v", err)
+	}
+
+	var aggErr AggregateError
+	err := ValidateEnv([]string{"CERT=pem", "CERT_FILE=cert.pem"}, &config{})
+	if !errors.As(err, &aggErr) || len(aggErr.FieldParseErrors()) != 2 {
+		t.Fatalf("Expected an error per violated pair, got %v", err)
+	}
+}
+
+func TestUnmarshalQuoteStripping(t *testing.T) {
+	type config struct {
+		Double  string
+		Single  string
+		Nested  string
+		Lone    string
+		Port    int
+		Default string `env:",default='fallback'"`
+	}
+
+	env := []string{`DOUBLE="a b"`, `SINGLE='a'`, `NESTED="'a'"`, `LONE="`, `PORT=" 80 "`}
+
+	var out config
+	if err := Unmarshal(env, &out, WithQuoteStripping(), WithTrimSpace()); err == nil {
+		t.Fatal("Expected an error, as white space is trimmed before quotes are stripped")
+	}
+
+	env[len(env)-1] = `PORT="80"`
+	if err := Unmarshal(env, &out, WithQuoteStripping()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := config{Double: "a b", Single: "a", Nested: "'a'", Lone: `"`, Port: 80, Default: "fallback"}
+	if out != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, out)
+	}
+
+	out = config{}
+	if err := Unmarshal([]string{"DOUBLE=`a`", `SINGLE="a"`, "PORT=<<80>>"}, &out, WithQuoteStripping([2]string{"`", "`"}, [2]string{"<<", ">>"})); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Double != "a" || out.Single != `"a"` || out.Port != 80 {
+		t.Fatalf("Expected only the custom quotes to be stripped, got %+v", out)
+	}
+}
+
+func TestUnmarshalAfterSetError(t *testing.T) {
+	var out struct {
+		Host string
+		Port int
+	}
+
+	errRejected := errors.New("rejected")
+	hook := WithAfterSet(func(fieldPath, envVar string, value reflect.Value, secret bool) error {
+		if fieldPath == "Port" {
+			return errRejected
+		}
+		return nil
+	})
+
+	var fieldErr FieldParseError
+	err := Unmarshal([]string{"HOST=localhost", "PORT=80"}, &out, hook)
+	if !errors.As(err, &fieldErr) || fieldErr.Field() != "Port" || !errors.Is(err, errRejected) {
+		t.Fatalf("Expected a FieldParseError wrapping the hook's error, got %v", err)
+	}
+}
+
+func TestUnmarshalEnableIf(t *testing.T) {
+	type config struct {
+		MetricsEnabled bool `env:",default=false"`
+		Metrics        struct {
+			Endpoint string `env:",required"`
+		} `env:",enableIf=METRICS_ENABLED"`
+		Tracing string `env:",enableIf=TRACING_ENABLED"`
+	}
+
+	tt := []struct {
+		name     string
+		env      []string
+		endpoint string
+		tracing  string
+		err      string
+	}{
+		{"gate unset", []string{"METRICS_ENDPOINT=ignored", "TRACING=ignored"}, "", "", ""},
+		{"gate false", []string{"METRICS_ENABLED=false", "TRACING_ENABLED=0", "TRACING=ignored"}, "", "", ""},
+		{"gate true", []string{"METRICS_ENABLED=true", "METRICS_ENDPOINT=http://metrics", "TRACING_ENABLED=1", "TRACING=on"}, "http://metrics", "on", ""},
+		{"required when enabled", []string{"METRICS_ENABLED=true"}, "", "", "missing required value"},
+		{"invalid gate", []string{"TRACING_ENABLED=maybe"}, "", "", "invalid value for enableIf variable TRACING_ENABLED"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			err := Unmarshal(tc.env, &out)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.Metrics.Endpoint != tc.endpoint || out.Tracing != tc.tracing {
+				t.Fatalf("Expected endpoint %q and tracing %q, got %+v", tc.endpoint, tc.tracing, out)
+			}
+		})
+	}
+}
+
+func TestUnmarshalFallbackTag(t *testing.T) {
+	type Common struct {
+		Region string `json:"region"`
+	}
+
+	type Named struct {
+		Zone string `json:"zone"`
+	}
+
+	var out struct {
+		Common
+		Named      `json:"placement"`
+		SigningKey string `json:"signingKey,omitempty"`
+		Port       int    `env:"HTTP_PORT" json:"port"`
+		Ignored    string `json:"-"`
+		Options    string `json:",omitempty"`
+		Auth       struct {
+			Token string `json:"access_token"`
+		} `json:"authentication"`
+	}
+
+	env := []string{
+		"APP_REGION=eu", "APP_PLACEMENT_ZONE=a", "APP_SIGNING_KEY=key", "HTTP_PORT=80", "APP_IGNORED=yes",
+		"APP_OPTIONS=opts", "APP_AUTHENTICATION_ACCESS_TOKEN=token",
+	}
+	if err := Unmarshal(env, &out, WithPrefix("APP"), WithFallbackTag("json")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Region != "eu" || out.Zone != "a" || out.SigningKey != "key" || out.Port != 80 || out.Ignored != "yes" ||
+		out.Options != "opts" || out.Auth.Token != "token" {
+		t.Fatalf("Expected names to be derived from json tags, got %+v", out)
+	}
+}
+
+func TestUnmarshalAllowUnexported(t *testing.T) {
+	type internal struct {
+		host    string
+		timeout time.Duration
+		Exposed bool
+		tags    []string `env:"TAG_LIST"`
+		nested  struct {
+			level int
+		}
+	}
+
+	env := []string{"HOST=localhost", "TIMEOUT=5s", "EXPOSED=true", "TAG_LIST=a,b", "NESTED_LEVEL=3"}
+
+	var out internal
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.host != "" || out.timeout != 0 || !out.Exposed || out.tags != nil || out.nested.level != 0 {
+		t.Fatalf("Expected unexported fields to be skipped by default, got %+v", out)
+	}
+
+	if err := Unmarshal(env, &out, WithAllowUnexported()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.host != "localhost" || out.timeout != 5*time.Second || !reflect.DeepEqual(out.tags, []string{"a", "b"}) ||
+		out.nested.level != 3 {
+		t.Fatalf("Expected unexported fields to be populated, got %+v", out)
+	}
+
+	vars, err := Marshal(out, WithAllowUnexported())
+	if err != nil || !reflect.DeepEqual(vars, env) {
+		t.Fatalf("Expected %q, got %q (%v)", env, vars, err)
+	}
+
+	if infos := Describe(&out, WithAllowUnexported()); len(infos) != 5 || infos[4].Field != "nested.level" {
+		t.Fatalf("Expected unexported fields to be described, got %+v", infos)
+	}
+}
+
+func TestUnmarshalDefaultFrom(t *testing.T) {
+	type config struct {
+		Region  string `env:",default=us-east-1"`
+		Primary string `env:",defaultFrom=SECONDARY default=literal"`
+		Replica string `env:",defaultFrom=REGION"`
+		Backup  string `env:",defaultFrom=BACKUP_FALLBACK required"`
+	}
+
+	tt := []struct {
+		name     string
+		env      []string
+		expected config
+		err      string
+	}{
+		{
+			name:     "primary set",
+			env:      []string{"PRIMARY=a", "SECONDARY=b", "BACKUP=c"},
+			expected: config{Region: "us-east-1", Primary: "a", Replica: "us-east-1", Backup: "c"},
+		},
+		{
+			name:     "secondary set",
+			env:      []string{"SECONDARY=b", "REGION=eu-west-1", "BACKUP_FALLBACK=c"},
+			expected: config{Region: "eu-west-1", Primary: "b", Replica: "eu-west-1", Backup: "c"},
+		},
+		{
+			name:     "neither set",
+			env:      []string{"BACKUP_FALLBACK=c"},
+			expected: config{Region: "us-east-1", Primary: "literal", Replica: "us-east-1", Backup: "c"},
+		},
+		{
+			name: "required",
+			env:  nil,
+			err:  "missing required value",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			err := Unmarshal(tc.env, &out)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out != tc.expected {
+				t.Fatalf("Expected %+v, got %+v", tc.expected, out)
+			}
+		})
+	}
+
+	var fieldErr FieldParseError
+	var typed struct {
+		Port int `env:",defaultFrom=LEGACY_PORT"`
+	}
+
+	if err := Unmarshal([]string{"LEGACY_PORT=abc"}, &typed); !errors.As(err, &fieldErr) || fieldErr.EnvVar() != "LEGACY_PORT" {
+		t.Fatalf("Expected a FieldParseError naming LEGACY_PORT, got %v", err)
+	}
+}
+
+func TestUnmarshalBoolPointerTriState(t *testing.T) {
+	type config struct {
+		Override *bool
+	}
+
+	tt := []struct {
+		name     string
+		env      []string
+		opts     []Option
+		expected *bool
+		err      bool
+	}{
+		{name: "unset", env: nil},
+		{name: "true", env: []string{"OVERRIDE=true"}, expected: func() *bool { v := true; return &v }()},
+		{name: "false", env: []string{"OVERRIDE=false"}, expected: new(bool)},
+		{name: "empty", env: []string{"OVERRIDE="}, err: true},
+		{name: "empty as unset", env: []string{"OVERRIDE="}, opts: []Option{WithTreatEmptyAsUnset()}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			err := Unmarshal(tc.env, &out, tc.opts...)
+			if tc.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(out.Override, tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, out.Override)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTreatEmptyAsUnset(t *testing.T) {
+	var out struct {
+		Host    string `env:",alias=LEGACY_HOST"`
+		Port    int    `env:",default=8080"`
+		Token   string `env:",required"`
+		Comment string
+	}
+
+	env := []string{"HOST=", "LEGACY_HOST=legacy", "PORT=", "TOKEN=t", "COMMENT="}
+	if err := Unmarshal(env, &out, WithTreatEmptyAsUnset()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Host != "legacy" || out.Port != 8080 || out.Comment != "" {
+		t.Fatalf("Expected empty values to be treated as unset, got %+v", out)
+	}
+
+	err := Unmarshal([]string{"TOKEN="}, &out, WithTreatEmptyAsUnset())
+	if err == nil || !strings.Contains(err.Error(), "missing required value") {
+		t.Fatalf("Expected a missing required value error, got %v", err)
+	}
+}
+
+func TestUnmarshalAllowEmpty(t *testing.T) {
+	type config struct {
+		Suffix string  `env:",allowEmpty alias=LEGACY_SUFFIX default=.log"`
+		Prefix string  `env:",default=app"`
+		Token  *string `env:",allowEmpty required"`
+	}
+
+	tt := []struct {
+		name     string
+		env      []string
+		opts     []Option
+		expected config
+	}{
+		{"empty values are values by default", []string{"SUFFIX=", "PREFIX=", "TOKEN="}, nil, config{"", "", new(string)}},
+		{"allowEmpty overrides empty as unset", []string{"SUFFIX=", "LEGACY_SUFFIX=.txt", "PREFIX=", "TOKEN="},
+			[]Option{WithTreatEmptyAsUnset()}, config{"", "app", new(string)}},
+		{"unset still falls back", []string{"LEGACY_SUFFIX=.txt", "TOKEN=t"},
+			[]Option{WithTreatEmptyAsUnset()}, config{".txt", "app", &[]string{"t"}[0]}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			if err := Unmarshal(tc.env, &out, tc.opts...); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(out, tc.expected) {
+				t.Fatalf("Expected %+v, got %+v", tc.expected, out)
+			}
+		})
+	}
+
+	fallback := WithFallback(map[string]string{"SUFFIX": ""})
+	var out config
+	if err := Unmarshal([]string{"TOKEN=t"}, &out, WithTreatEmptyAsUnset(), fallback); err != nil || out.Suffix != "" {
+		t.Fatalf("Expected the empty fallback value to be used, got %q (%v)", out.Suffix, err)
+	}
+}
+
+func TestUnmarshalLowercaseNames(t *testing.T) {
+	var out struct {
+		JSONString string
+		Port       int `env:"HTTP_PORT"`
+		Renamed    string
+		Auth       struct {
+			SigningKey string
+		}
+	}
+
+	env := []string{"app_json_string=json", "HTTP_PORT=80", "Custom=renamed", "app_auth_signing_key=key"}
+	opts := []Option{WithPrefix("app"), WithLowercaseNames(), WithFieldOverrides(map[string]string{"Renamed": "Custom"})}
+	if err := Unmarshal(env, &out, opts...); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.JSONString != "json" || out.Port != 80 || out.Renamed != "renamed" || out.Auth.SigningKey != "key" {
+		t.Fatalf("Expected lower case names to be used, got %+v", out)
+	}
+}
+
+func TestUnmarshalErrorSentinels(t *testing.T) {
+	tt := []struct {
+		name   string
+		env    []string
+		out    any
+		target error
+	}{
+		{"required", nil, &struct {
+			Token string `env:",required"`
+		}{}, ErrRequired},
+		{"required with message", nil, &struct {
+			Token string `env:",required msg=from\\sthe\\sdashboard"`
+		}{}, ErrRequired},
+		{"required if", []string{"TLS=true"}, &struct {
+			TLS  bool
+			Cert string `env:",requiredIf=TLS=true"`
+		}{}, ErrRequired},
+		{"require all", nil, &struct{ Port int }{}, ErrRequired},
+		{"unsupported type", []string{"DONE=1"}, &struct{ Done chan int }{}, ErrUnsupportedType},
+		{"unsupported element type", []string{"SIGNALS=1"}, &struct{ Signals []complex64 }{}, ErrUnsupportedType},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var fieldErr FieldParseError
+			err := Unmarshal(tc.env, tc.out, WithRequireAll())
+			if !errors.As(err, &fieldErr) || !errors.Is(err, tc.target) {
+				t.Fatalf("Expected a FieldParseError wrapping %v, got %v", tc.target, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalDeclaredPrefix(t *testing.T) {
+	type auth struct {
+		_     struct{} `env:",prefix=OAUTH"`
+		Token string
+	}
+
+	type config struct {
+		_    struct{} `env:",prefix=APP"`
+		Port int
+		Host string `env:"HOSTNAME"`
+		Auth auth
+	}
+
+	tt := []struct {
+		name   string
+		prefix string
+		env    []string
+	}{
+		{"declared only", "", []string{"APP_PORT=80", "HOSTNAME=localhost", "APP_AUTH_OAUTH_TOKEN=t"}},
+		{"combined with the call site", "ACME", []string{"ACME_APP_PORT=80", "HOSTNAME=localhost", "ACME_APP_AUTH_OAUTH_TOKEN=t"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out config
+			if err := UnmarshalPrefix(tc.env, &out, tc.prefix, WithAllowUnexported()); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if out.Port != 80 || out.Host != "localhost" || out.Auth.Token != "t" {
+				t.Fatalf("Expected the declared prefixes to be used, got %+v", out)
+			}
+
+			vars, err := MarshalPrefix(out, tc.prefix)
+			if err != nil || !reflect.DeepEqual(vars, tc.env) {
+				t.Fatalf("Expected %q, got %q (%v)", tc.env, vars, err)
+			}
+
+			if infos := Describe(&out, WithPrefix(tc.prefix)); len(infos) != 3 || infos[0].EnvVar != tc.env[0][:strings.Index(tc.env[0], "=")] {
+				t.Fatalf("Expected the declared prefix to be described, got %+v", infos)
+			}
+		})
+	}
+}
+
+func TestUnmarshalArgs(t *testing.T) {
+	var out struct {
+		Name  string
+		Query string
+	}
+
+	args := []string{"./cmd", "-v", "=ignored", "NAME", "QUERY=a=b", "NAME=x", "--name=y", "-NAME=z"}
+	if err := UnmarshalArgs(args, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if out.Name != "x" || out.Query != "a=b" {
+		t.Fatalf("Expected only KEY=VALUE arguments to be used, got %+v", out)
+	}
+}
+
+func TestUnmarshalWithDefaults(t *testing.T) {
+	type config struct {
+		Name    string `env:",required"`
+		Retries int
+		secret  string
+		Auth    struct {
+			Issuer string
+			TTL    time.Duration
+		}
+	}
+
+	defaults := config{Name: "svc", Retries: 3, secret: "s3cret"}
+	defaults.Auth.TTL = time.Hour
+
+	var populated []string
+	out := config{Retries: 1}
+	err := UnmarshalWithDefaults([]string{"AUTH_ISSUER=me"}, &out, &defaults, WithAllowUnexported(),
+		WithTrace(func(fieldPath, envVar string, found bool, source string) {
+			if source == "defaults" {
+				populated = append(populated, fieldPath)
+			}
+		}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := config{Name: "svc", Retries: 3, secret: "s3cret"}
+	expected.Auth.Issuer, expected.Auth.TTL = "me", time.Hour
+	if out != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, out)
+	}
+
+	if !reflect.DeepEqual(populated, []string{"Name", "Retries", "secret", "Auth.TTL"}) {
+		t.Fatalf("Expected defaults to be traced, got %q", populated)
+	}
+
+	if err := UnmarshalWithDefaults(nil, &out, config{}); !errors.Is(err, ErrRequired) {
+		t.Fatalf("Expected ErrRequired with a zero default, got %v", err)
+	}
+
+	if err := UnmarshalWithDefaults(nil, &out, struct{ Name string }{}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("Expected ErrInvalidTarget for mismatched defaults, got %v", err)
+	}
+}
+
+type pathTestBackend struct {
+	Weights map[string]int
+}
+
+func (b *pathTestBackend) UnmarshalEnv(v string) error {
+	return Unmarshal(strings.Fields(v), b)
+}
+
+func TestFieldParseErrorPath(t *testing.T) {
+	var out struct {
+		Tags     []int
+		Servers  map[string]struct{ Port int }
+		Backends []pathTestBackend
+		Auth     struct {
+			SigningKey int `env:"KEY"`
+		}
+	}
+
+	tt := []struct {
+		env      string
+		expected []string
+	}{
+		{"TAGS=1,x", []string{"Tags", "1"}},
+		{"SERVERS_EU.WEST_PORT=http", []string{"Servers", "EU.WEST", "Port"}},
+		{"BACKENDS=WEIGHTS=a=x", []string{"Backends", "0", "Weights", "a"}},
+		{"KEY=k", []string{"Auth", "SigningKey"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.env, func(t *testing.T) {
+			var fieldErr FieldParseError
+			if err := Unmarshal([]string{tc.env}, &out); !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if path := fieldErr.Path(); !reflect.DeepEqual(path, tc.expected) {
+				t.Fatalf("Expected path %q, got %q", tc.expected, path)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMaxDepth(t *testing.T) {
+	type level2 struct{ Value int }
+	type level1 struct{ Inner level2 }
+
+	var out struct {
+		Outer   level1
+		Servers map[string]level1
+	}
+
+	env := []string{"OUTER_INNER_VALUE=1", "SERVERS_WEB_INNER_VALUE=2"}
+	if err := Unmarshal(env, &out); err != nil {
+		t.Fatalf("Expected no error within the default depth, got %v", err)
+	}
+
+	if out.Outer.Inner.Value != 1 || out.Servers["WEB"].Inner.Value != 2 {
+		t.Fatalf("Expected nested fields to be set, got %+v", out)
+	}
+
+	if err := Unmarshal(env, &out, WithMaxDepth(2)); err != nil {
+		t.Fatalf("Expected no error at the maximum depth, got %v", err)
+	}
+
+	var servers struct {
+		Servers map[string]level1
+	}
+
+	tt := []struct {
+		depth    int
+		out      any
+		expected string
+	}{
+		{1, &out, "Outer.Inner"},
+		{0, &out, "Outer"},
+		{1, &servers, "Servers[WEB].Inner"},
+		{0, &servers, "Servers"},
+	}
+
+	for _, tc := range tt {
+		var fieldErr FieldParseError
+		err := Unmarshal(env, tc.out, WithMaxDepth(tc.depth))
+		if !errors.As(err, &fieldErr) || !errors.Is(err, ErrMaxDepth) || fieldErr.Field() != tc.expected {
+			t.Fatalf("Expected a FieldParseError wrapping ErrMaxDepth for %s, got %v", tc.expected, err)
+		}
+	}
+
+	if _, err := Marshal(out, WithMaxDepth(1)); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("Expected Marshal to return ErrMaxDepth, got %v", err)
+	}
+}
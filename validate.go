@@ -0,0 +1,170 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validatorFunc validates a field after it has been set from the raw value.
+type validatorFunc func(value string, field reflect.Value) error
+
+// tagValidator returns a function which validates a field's value against the constraints specified in its tag,
+// or nil if the tag specifies no constraints.
+func tagValidator(tag fieldTag) validatorFunc {
+	var validators []validatorFunc
+	if tag.HasLen || tag.HasMaxLen {
+		validators = append(validators, lengthValidator(tag))
+	}
+
+	if tag.HasMin || tag.HasMax {
+		validators = append(validators, rangeValidator(tag))
+	}
+
+	if len(tag.OneOf) > 0 {
+		validators = append(validators, oneOfValidator(tag))
+	}
+
+	if len(validators) == 0 {
+		return nil
+	}
+
+	return func(value string, field reflect.Value) error {
+		for _, validate := range validators {
+			if err := validate(value, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// lengthValidator validates the number of runes in string values.
+func lengthValidator(tag fieldTag) validatorFunc {
+	return func(_ string, field reflect.Value) error {
+		if field.Kind() != reflect.String {
+			return nil
+		}
+
+		length := utf8.RuneCountInString(field.String())
+		if tag.HasLen && length != tag.Len {
+			return fmt.Errorf("length must be exactly %d but got %d", tag.Len, length)
+		}
+
+		if tag.HasMaxLen && length > tag.MaxLen {
+			return fmt.Errorf("length must be at most %d but got %d", tag.MaxLen, length)
+		}
+
+		return nil
+	}
+}
+
+// rangeValidator validates that integer and float values fall within the inclusive bounds of the tag.
+func rangeValidator(tag fieldTag) validatorFunc {
+	return func(_ string, field reflect.Value) error {
+		if tag.HasMin {
+			cmp, err := compareNumber(field, tag.Min)
+			if err != nil {
+				return fmt.Errorf("invalid min option %q: %w", tag.Min, err)
+			}
+
+			if cmp < 0 {
+				return fmt.Errorf("must be at least %s but got %v", tag.Min, field.Interface())
+			}
+		}
+
+		if tag.HasMax {
+			cmp, err := compareNumber(field, tag.Max)
+			if err != nil {
+				return fmt.Errorf("invalid max option %q: %w", tag.Max, err)
+			}
+
+			if cmp > 0 {
+				return fmt.Errorf("must be at most %s but got %v", tag.Max, field.Interface())
+			}
+		}
+
+		return nil
+	}
+}
+
+// compareNumber compares a numeric field to bound, which is parsed according to the kind of the field.
+// It returns -1, 0 or 1 when the field is less than, equal to or greater than the bound respectively.
+// Fields which are not numeric always compare as equal.
+func compareNumber(field reflect.Value, bound string) (int, error) {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b, err := strconv.ParseInt(bound, 10, 64)
+		return compare(field.Int(), b), err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b, err := strconv.ParseUint(bound, 10, 64)
+		return compare(field.Uint(), b), err
+	case reflect.Float32, reflect.Float64:
+		b, err := strconv.ParseFloat(bound, 64)
+		return compare(field.Float(), b), err
+	default:
+		return 0, nil
+	}
+}
+
+func compare[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// oneOfValidator validates that a value is one of an allowed set. String values are compared as set on the field,
+// while values of any other kind are compared as provided.
+func oneOfValidator(tag fieldTag) validatorFunc {
+	return func(value string, field reflect.Value) error {
+		if field.Kind() == reflect.String {
+			value = field.String()
+		}
+
+		for _, allowed := range tag.OneOf {
+			if value == allowed || (tag.IgnoreCase && strings.EqualFold(value, allowed)) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("must be one of %v but got %q", tag.OneOf, value)
+	}
+}
+
+// validatingSetter validates the value set by next, which is set on a copy of the field, such that the field itself
+// is only set once the value is valid. Existing pointer allocations of the field are reused, just as by next.
+type validatingSetter struct {
+	next     fieldSetter
+	validate validatorFunc
+}
+
+func (v validatingSetter) Set(value string, field reflect.Value) error {
+	for field.Kind() == reflect.Pointer && !field.IsNil() {
+		field = field.Elem()
+	}
+
+	scratch := reflect.New(field.Type()).Elem()
+	scratch.Set(field)
+	if err := v.next.Set(value, scratch); err != nil {
+		return err
+	}
+
+	validated := scratch
+	for validated.Kind() == reflect.Pointer {
+		validated = validated.Elem()
+	}
+
+	if err := v.validate(value, validated); err != nil {
+		return err
+	}
+
+	field.Set(scratch)
+	return nil
+}
@@ -0,0 +1,173 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalLengthConstraints(t *testing.T) {
+	tt := []struct {
+		tag   string
+		value string
+		err   string
+	}{
+		{`env:"COUNTRY,len=2"`, "US", ""},
+		{`env:"COUNTRY,len=2"`, "日本", ""},
+		{`env:"COUNTRY,len=2"`, "USA", "length must be exactly 2 but got 3"},
+		{`env:"COUNTRY,maxlen=3"`, "USA", ""},
+		{`env:"COUNTRY,maxlen=3"`, "USAA", "length must be at most 3 but got 4"},
+		{`env:"COUNTRY,maxlen=three"`, "USA", `invalid maxlen option "three": strconv.Atoi: parsing "three": invalid syntax`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.tag+"="+tc.value, func(t *testing.T) {
+			outType := reflect.StructOf([]reflect.StructField{
+				{Name: "Country", Type: reflect.TypeOf((*string)(nil)), Tag: reflect.StructTag(tc.tag)},
+			})
+
+			err := Unmarshal([]string{"COUNTRY=" + tc.value}, reflect.New(outType).Interface())
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.Unwrap().Error(); actual != tc.err {
+				t.Fatalf("Expected %q to equal %q", actual, tc.err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalOneOf(t *testing.T) {
+	tt := []struct {
+		tag   string
+		value string
+		err   string
+	}{
+		{`env:"LEVEL,oneof=debug info warn error"`, "info", ""},
+		{`env:"LEVEL,oneof=debug info warn error"`, "INFO", `must be one of [debug info warn error] but got "INFO"`},
+		{`env:"LEVEL,ignorecase oneof=debug info warn error"`, "INFO", ""},
+		{`env:"LEVEL,required oneof=debug info default=info"`, "verbose", `must be one of [debug info] but got "verbose"`},
+		{`env:"LEVEL,oneof=text|json"`, "json", ""},
+		{`env:"LEVEL,oneof=text|json"`, "xml", `must be one of [text json] but got "xml"`},
+		{`env:"LEVEL,oneof=text json"`, "text", `ambiguous "json" following the oneof option: place options before ` +
+			`oneof, and separate values which are keywords with |, e.g. oneof=text|json`},
+		{`env:"LEVEL,oneof=debug info ignorecase"`, "info", `ambiguous "ignorecase" following the oneof option: ` +
+			`place options before oneof, and separate values which are keywords with |, e.g. oneof=text|json`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.tag+"="+tc.value, func(t *testing.T) {
+			outType := reflect.StructOf([]reflect.StructField{
+				{Name: "Level", Type: reflect.TypeOf(""), Tag: reflect.StructTag(tc.tag)},
+			})
+
+			err := Unmarshal([]string{"LEVEL=" + tc.value}, reflect.New(outType).Interface())
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.Unwrap().Error(); actual != tc.err {
+				t.Fatalf("Expected %q to equal %q", actual, tc.err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalOneOfNonString(t *testing.T) {
+	var out struct {
+		Replicas int `env:",oneof=1 3 5"`
+	}
+
+	if err := Unmarshal([]string{"REPLICAS=3"}, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := Unmarshal([]string{"REPLICAS=2"}, &out); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestUnmarshalRangeConstraints(t *testing.T) {
+	tt := []struct {
+		fieldType reflect.Type
+		tag       string
+		value     string
+		err       string
+	}{
+		{reflect.TypeOf(0), `env:"WORKERS,min=1 max=128"`, "1", ""},
+		{reflect.TypeOf(0), `env:"WORKERS,min=1 max=128"`, "128", ""},
+		{reflect.TypeOf(0), `env:"WORKERS,min=1 max=128"`, "0", "must be at least 1 but got 0"},
+		{reflect.TypeOf(0), `env:"WORKERS,min=1 max=128"`, "129", "must be at most 128 but got 129"},
+		{reflect.TypeOf(int8(0)), `env:"WORKERS,min=-5"`, "-6", "must be at least -5 but got -6"},
+		{reflect.TypeOf(uint(0)), `env:"WORKERS,max=10"`, "11", "must be at most 10 but got 11"},
+		{reflect.TypeOf(uint(0)), `env:"WORKERS,min=10"`, "18446744073709551615", ""},
+		{reflect.TypeOf(0.0), `env:"WORKERS,min=0.5 max=1"`, "0.25", "must be at least 0.5 but got 0.25"},
+		{reflect.TypeOf((*float32)(nil)), `env:"WORKERS,max=1"`, "1.5", "must be at most 1 but got 1.5"},
+		{reflect.TypeOf(0), `env:"WORKERS,max=ten"`, "1", `invalid max option "ten": strconv.ParseInt: parsing "ten": invalid syntax`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.fieldType.String()+" "+tc.tag+"="+tc.value, func(t *testing.T) {
+			outType := reflect.StructOf([]reflect.StructField{
+				{Name: "Workers", Type: tc.fieldType, Tag: reflect.StructTag(tc.tag)},
+			})
+
+			err := Unmarshal([]string{"WORKERS=" + tc.value}, reflect.New(outType).Interface())
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErr FieldParseError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Expected a FieldParseError, got %v", err)
+			}
+
+			if actual := fieldErr.Unwrap().Error(); actual != tc.err {
+				t.Fatalf("Expected %q to equal %q", actual, tc.err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalidValueLeavesFieldUntouched(t *testing.T) {
+	workers, ratio := 4, 0.5
+	out := struct {
+		Workers int      `env:"WORKERS,max=10"`
+		Ratio   *float64 `env:"RATIO,max=1"`
+		Level   string   `env:"LEVEL,oneof=debug info"`
+	}{Workers: workers, Ratio: &ratio, Level: "info"}
+
+	for _, env := range []string{"WORKERS=99", "RATIO=2", "LEVEL=trace"} {
+		if err := Unmarshal([]string{env}, &out); err == nil {
+			t.Fatalf("Expected an error for %s", env)
+		}
+	}
+
+	if out.Workers != 4 || out.Ratio != &ratio || ratio != 0.5 || out.Level != "info" {
+		t.Fatalf("Expected invalid values not to be set, got %d %v %s", out.Workers, *out.Ratio, out.Level)
+	}
+
+	if err := Unmarshal([]string{"RATIO=0.75"}, &out); err != nil || out.Ratio != &ratio || ratio != 0.75 {
+		t.Fatalf("Expected a valid value to reuse the existing allocation, got %v, %v", err, *out.Ratio)
+	}
+}